@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/Houndie/toolbox/pkg/toolbox"
 	"github.com/spf13/cobra"
@@ -35,9 +36,20 @@ const goimportsFlag = "goimports"
 const toolsfileFlag = "tools_file"
 const toolsdirFlag = "tools_directory"
 const configfileFlag = "config_file"
+const lockfileFlag = "lock_file"
+const artifactsfileFlag = "artifacts_file"
 const basedirFlag = "base_dir"
+const toolsmoduleFlag = "tools_module"
+const modeFlag = "mode"
+const backendFlag = "backend"
 const buildFlagsFlag = "build_flags"
 const verboseFlag = "verbose"
+const jobsFlag = "jobs"
+const forceFlag = "force"
+const strictFlag = "strict"
+const isolatedFlag = "isolated"
+const allowedCommandsFlag = "allowed_commands"
+const tasksKey = "tasks"
 
 func init() {
 	rootCmd.PersistentFlags().String(goFlag, "", "The \"go\" executable to use.")
@@ -55,6 +67,21 @@ func init() {
 	rootCmd.PersistentFlags().String(toolsdirFlag, "", "the directory where tool binaries are stored.  Defaults to \"_tools\" in the base directory")
 	viper.BindPFlag(toolsdirFlag, rootCmd.PersistentFlags().Lookup(toolsdirFlag))
 
+	rootCmd.PersistentFlags().String(lockfileFlag, "", "the file in which to record checksums of installed tools.  Defaults to \"toolbox.lock\" in the base directory.")
+	viper.BindPFlag(lockfileFlag, rootCmd.PersistentFlags().Lookup(lockfileFlag))
+
+	rootCmd.PersistentFlags().String(artifactsfileFlag, "", "the file in which to store tools that are downloaded as prebuilt binaries.  Defaults to \"artifacts.json\" in the base directory.")
+	viper.BindPFlag(artifactsfileFlag, rootCmd.PersistentFlags().Lookup(artifactsfileFlag))
+
+	rootCmd.PersistentFlags().String(toolsmoduleFlag, "", "the directory of a separate go module in which to track tools, keeping them out of your project's go.mod.  Relative paths are relative to the base directory.  When set, the tools file defaults to \"tools.go\" in this directory.")
+	viper.BindPFlag(toolsmoduleFlag, rootCmd.PersistentFlags().Lookup(toolsmoduleFlag))
+
+	rootCmd.PersistentFlags().String(modeFlag, "", "how tools are tracked and built.  \"shared\" (the default) tracks every tool in one go.mod, \"per_tool\" pins versions in the tools file and builds each tool from its own generated module in the tools directory, and \"global\" pins versions in the tools file and installs each tool with \"go install pkg@version\", never touching a go.mod.")
+	viper.BindPFlag(modeFlag, rootCmd.PersistentFlags().Lookup(modeFlag))
+
+	rootCmd.PersistentFlags().String(backendFlag, "", "where the list of tools is stored.  \"toolsfile\" (the default) stores tools as imports in the tools file, \"gomod\" stores them as tool directives in go.mod, which requires go 1.24 or newer.")
+	viper.BindPFlag(backendFlag, rootCmd.PersistentFlags().Lookup(backendFlag))
+
 	rootCmd.PersistentFlags().String(buildFlagsFlag, "", "Any build flags to use when adding a new tool. These are stored and used when syncing the tool in the future.")
 	viper.BindPFlag(buildFlagsFlag, rootCmd.PersistentFlags().Lookup(buildFlagsFlag))
 
@@ -92,7 +119,7 @@ func toolsDir() (string, error) {
 	return toolsdir, nil
 }
 
-func makeOptions() []toolbox.Option {
+func makeOptions() ([]toolbox.Option, error) {
 	options := []toolbox.Option{}
 	if goOption := viper.GetString(goFlag); goOption != "" {
 		options = append(options, toolbox.GoOption(goOption))
@@ -109,12 +136,123 @@ func makeOptions() []toolbox.Option {
 	if toolsdirOption := viper.GetString(toolsdirFlag); toolsdirOption != "" {
 		options = append(options, toolbox.ToolsdirOption(toolsdirOption))
 	}
+	if lockfileOption := viper.GetString(lockfileFlag); lockfileOption != "" {
+		options = append(options, toolbox.LockfileOption(lockfileOption))
+	}
+	if artifactsfileOption := viper.GetString(artifactsfileFlag); artifactsfileOption != "" {
+		options = append(options, toolbox.ArtifactsfileOption(artifactsfileOption))
+	}
+	if toolsmoduleOption := viper.GetString(toolsmoduleFlag); toolsmoduleOption != "" {
+		options = append(options, toolbox.ToolsmoduleOption(toolsmoduleOption))
+	}
+	if modeOption := viper.GetString(modeFlag); modeOption != "" {
+		mode, err := toolbox.ParseMode(modeOption)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, toolbox.ModeOption(mode))
+	}
+	if backendOption := viper.GetString(backendFlag); backendOption != "" {
+		backend, err := toolbox.ParseBackend(backendOption)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, toolbox.BackendOption(backend))
+	}
 	if buildFlagsOption := viper.GetString(buildFlagsFlag); buildFlagsOption != "" {
 		options = append(options, toolbox.BuildFlagsOption(buildFlagsOption))
 	}
+	if jobsOption := viper.GetInt(jobsFlag); jobsOption > 0 {
+		options = append(options, toolbox.JobsOption(jobsOption))
+	}
+	if forceOption := viper.GetBool(forceFlag); forceOption {
+		options = append(options, toolbox.ForceOption(forceOption))
+	}
+	if strictOption := viper.GetBool(strictFlag); strictOption {
+		options = append(options, toolbox.StrictOption(strictOption))
+	}
+	if isolatedOption := viper.GetBool(isolatedFlag); isolatedOption {
+		options = append(options, toolbox.IsolatedOption(isolatedOption))
+	}
+	if allowedCommandsOption := viper.GetStringSlice(allowedCommandsFlag); len(allowedCommandsOption) > 0 {
+		options = append(options, toolbox.AllowedCommandsOption(allowedCommandsOption))
+	}
 	if verboseOption := viper.GetBool(verboseFlag); verboseOption {
 		options = append(options, toolbox.LoggerOption(log.New(os.Stdout, "", 0)))
 	}
 
-	return options
+	return options, nil
+}
+
+// configTasks reads the tasks section of the configuration file, which is only done for the run command, so that a broken task doesn't break every other command.  A task is either a single command line, or a map with "run" (one command line or a list of them), "description", "deps", and "env".  Env is a list of "NAME=value" strings, since configuration keys are case insensitive.
+func configTasks() ([]*toolbox.Task, error) {
+	if !viper.IsSet(tasksKey) {
+		return nil, nil
+	}
+	raw, ok := viper.Get(tasksKey).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be a map of task names to tasks", tasksKey)
+	}
+
+	tasks := []*toolbox.Task{}
+	for name, value := range raw {
+		task := &toolbox.Task{Name: name}
+		tasks = append(tasks, task)
+		if line, ok := value.(string); ok {
+			task.Run = []string{line}
+			continue
+		}
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("task %s must be either a command line or a map", name)
+		}
+		for key, field := range fields {
+			var err error
+			switch key {
+			case "description":
+				task.Description = fmt.Sprint(field)
+			case "run":
+				task.Run, err = stringList(field)
+			case "deps":
+				task.Deps, err = stringList(field)
+			case "env":
+				var env []string
+				env, err = stringList(field)
+				task.Env = map[string]string{}
+				for _, e := range env {
+					parts := strings.SplitN(e, "=", 2)
+					if len(parts) != 2 {
+						return nil, fmt.Errorf("environment variable %s of task %s must be in the form NAME=value", e, name)
+					}
+					task.Env[parts[0]] = parts[1]
+				}
+			default:
+				return nil, fmt.Errorf("unknown field %s in task %s", key, name)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error reading %s of task %s: %w", key, name, err)
+			}
+		}
+	}
+	return tasks, nil
+}
+
+// stringList reads a configuration value that is either a single string, or a list of them
+func stringList(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		list := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a string, found %v", item)
+			}
+			list[i] = s
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("expected a string or a list of strings, found %v", value)
+	}
 }
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+const formatFlag = "format"
+
+const (
+	tableFormat = "table"
+	jsonFormat  = "json"
+	yamlFormat  = "yaml"
+	csvFormat   = "csv"
+)
+
+// isTerminal reports whether f is attached to a terminal, rather than a pipe or file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// isTemplateFormat reports whether format is a go text/template, rather than the name of a format
+func isTemplateFormat(format string) bool {
+	return strings.Contains(format, "{{")
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	j, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return fmt.Errorf("error marshalling to json: %w", err)
+	}
+	fmt.Fprintln(w, string(j))
+	return nil
+}
+
+func printYAML(w io.Writer, v interface{}) error {
+	y, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error marshalling to yaml: %w", err)
+	}
+	fmt.Fprint(w, string(y))
+	return nil
+}
+
+// printCSV writes out rows as comma separated values, with headers as the first row
+func printCSV(w io.Writer, headers []string, rows [][]string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(headers); err != nil {
+		return fmt.Errorf("error writing csv: %w", err)
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return fmt.Errorf("error writing csv: %w", err)
+	}
+	return nil
+}
+
+// printTemplate executes the text/template tmpl once for each item, with each execution on its own line
+func printTemplate(w io.Writer, tmpl string, items []interface{}) error {
+	t, err := template.New("format").Parse(tmpl)
+	if err != nil {
+		return fmt.Errorf("error parsing format template: %w", err)
+	}
+	for _, item := range items {
+		if err := t.Execute(w, item); err != nil {
+			return fmt.Errorf("error executing format template: %w", err)
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+// printTable writes out rows as aligned columns, with headers as the first row
+func printTable(w io.Writer, headers []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("error writing table: %w", err)
+	}
+	return nil
+}
+
+func unknownFormatError(format string) error {
+	return fmt.Errorf("unknown output format \"%s\"", format)
+}
@@ -3,8 +3,8 @@ package toolbox
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"reflect"
 
 	"github.com/kballard/go-shellquote"
 )
@@ -14,39 +14,42 @@ func Add(packageName string, options ...Option) error {
 	return AddVer(packageName, "", options...)
 }
 
-// AddVer adds a new tool found at packageName with a specific version to the vendoring system
+// AddVer adds a new tool found at packageName with a specific version to the vendoring system.  packageName may also be a pattern such as "golang.org/x/tools/cmd/...", in which case every command in the module matching the pattern is added at the same version.
+//
+// A second version of an already tracked tool may be installed side by side with the name "pkg@alias", such as "google.golang.org/protobuf/cmd/protoc-gen-go@1.3".  Side-by-side versions are built in their own module, are installed with the alias appended to the binary name, and default to the latest version matching the alias.
 func AddVer(packageName, version string, options ...Option) error {
 	p, err := parseOptions(options...)
 	if err != nil {
 		return fmt.Errorf("error parsing options: %w", err)
 	}
 
-	pkgVer := packageName
-	if version != "" {
-		pkgVer = packageName + "@" + version
+	packageName, alias := splitAlias(packageName)
+	if alias != "" {
+		if isPattern(packageName) {
+			return fmt.Errorf("side-by-side versions can't be added for pattern %s", packageName)
+		}
+		if !validName.MatchString(alias) {
+			return fmt.Errorf("invalid alias %s, aliases may only contain letters, numbers, '.', '_', and '-'", alias)
+		}
+		if version == "" {
+			version = aliasQuery(alias)
+		}
 	}
 
-	args := []string{"get", "-v"}
-	if p.buildFlags != "" {
-		split, err := shellquote.Split(p.buildFlags)
+	if isPattern(packageName) {
+		if p.name != "" {
+			return fmt.Errorf("a binary name can't be given for pattern %s, which may match multiple tools", packageName)
+		}
+		pkgs, mod, err := expandPattern(packageName, version, p)
 		if err != nil {
-			return fmt.Errorf("error splitting args: %w", err)
+			return err
 		}
-		args = append(args, split...)
-	}
-	args = append(args, pkgVer)
-
-	goget := exec.Command(p.goBinary, args...)
-	absToolsdir, err := filepath.Abs(p.toolsdirName)
-	if err != nil {
-		return fmt.Errorf("error finding absolute path to toolsdir %s: %w", p.toolsdirName, err)
-	}
-	goget.Env = append(os.Environ(), "GOBIN="+absToolsdir)
-	p.logger.Printf("calling \"%s\", with GOBIN=%s", shellquote.Join(goget.Args...), absToolsdir)
-	goget.Stdout = newLogWriter(p.logger)
-	goget.Stderr = newLogWriter(p.logger)
-	if err := goget.Run(); err != nil {
-		return fmt.Errorf("error calling go get: %w", err)
+		for _, pkg := range pkgs {
+			if err := AddVer(pkg, mod.Version, options...); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
 	tools, err := readTools(p)
@@ -55,29 +58,173 @@ func AddVer(packageName, version string, options ...Option) error {
 	}
 
 	needsUpdate := true
-	found := false
-	for _, tool := range tools {
-		if tool.Pkg == packageName {
-			if tool.BuildFlags == p.buildFlags {
+	var added *tool
+	tracked := false
+	for _, t := range tools {
+		if t.Pkg == packageName && t.Alias == "" {
+			tracked = true
+		}
+		if t.Pkg == packageName && t.Alias == alias {
+			if t.BuildFlags == p.buildFlags {
 				needsUpdate = false
 			} else {
-				tool.BuildFlags = p.buildFlags
+				t.BuildFlags = p.buildFlags
 			}
-			found = true
+			added = t
 			break
 		}
 	}
-	if !found {
-		tools = append(tools, &tool{
+	if alias != "" && !tracked {
+		return fmt.Errorf("%s must be added before side-by-side versions of it", packageName)
+	}
+	if added == nil {
+		added = &tool{
 			Pkg:        packageName,
+			Alias:      alias,
 			BuildFlags: p.buildFlags,
-		})
+		}
+		tools = append(tools, added)
+	}
+	// Every binary is installed into the same directory, and one would overwrite the other
+	renamed := *added
+	if p.name != "" {
+		renamed.Name = p.name
+	}
+	for _, t := range tools {
+		if t != added && binaryName(t) == binaryName(&renamed) {
+			return fmt.Errorf("the binary of %s would overwrite the binary of %s, use --name to install it under a different name", renamed.id(), t.id())
+		}
+	}
+	artifacts, err := readArtifacts(p)
+	if err != nil {
+		return err
+	}
+	for _, a := range artifacts {
+		if filepath.Base(artifactPath(a, p)) == binaryName(&renamed) {
+			return fmt.Errorf("the binary of %s would overwrite artifact %s, use --name to install it under a different name", renamed.id(), a.Name)
+		}
+	}
+	if p.name != "" && added.Name != p.name {
+		// The binary under the old name would otherwise be left behind
+		if err := os.Remove(binaryPath(added, p)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing old binary for %s: %w", packageName, err)
+		}
+		added.Name = p.name
+		needsUpdate = true
+	}
+	if len(p.env) > 0 && !reflect.DeepEqual(added.Env, p.env) {
+		added.Env = p.env
+		needsUpdate = true
+	}
+	// Shared mode records replacements in go.mod, but the modules of isolated tools are regenerated from the tools file
+	if isolated(added, p) && p.replace != "" && added.Replace != p.replace {
+		added.Replace = p.replace
+		needsUpdate = true
 	}
+	if isolated(added, p) && p.dropReplace && added.Replace != "" {
+		added.Replace = ""
+		needsUpdate = true
+	}
+
+	switch {
+	case p.mode == GlobalMode:
+		m, err := resolveModule(packageName, version, p)
+		if err != nil {
+			return err
+		}
+		if m.Version != added.Version || m.Path != added.Module {
+			added.Version = m.Version
+			added.Module = m.Path
+			needsUpdate = true
+		}
+	case isolated(added, p):
+		resolved, err := preparePerToolModule(added, version, p, p.logger)
+		if err != nil {
+			return err
+		}
+		if resolved.Version != added.Version || resolved.Path != added.Module {
+			added.Version = resolved.Version
+			added.Module = resolved.Path
+			needsUpdate = true
+		}
+	default:
+		if err := goGet(packageName, version, p); err != nil {
+			return err
+		}
+		if p.replace != "" {
+			if err := addReplace(packageName, p.replace, p.moduleDir, p, p.logger); err != nil {
+				return err
+			}
+		}
+		if p.dropReplace {
+			if err := dropReplace(packageName, p.moduleDir, p, p.logger); err != nil {
+				return err
+			}
+		}
+	}
+
 	if needsUpdate {
 		if err := writeTools(tools, p); err != nil {
 			return err
 		}
 	}
 
+	// Newer versions of go get no longer build binaries, so the tool is installed (and stamped) explicitly
+	parseFile, err := readTrackingModfile(p)
+	if err != nil {
+		return err
+	}
+	goVer, err := goVersion(p)
+	if err != nil {
+		return err
+	}
+	if err := installTool(added, p, p.logger); err != nil {
+		return err
+	}
+	s, err := newStamp(added, parseFile, goVer, p)
+	if err != nil {
+		return err
+	}
+	if err := s.write(p); err != nil {
+		return err
+	}
+	if err := writeLockfile(tools, map[string]bool{added.id(): true}, p); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// goGet records the given version of packageName in the module that tools are tracked in
+func goGet(packageName, version string, p *parsedOptions) error {
+	pkgVer := packageName
+	if version != "" {
+		pkgVer = packageName + "@" + version
+	}
+
+	args := []string{"get", "-v"}
+	flags, err := buildFlagArgs(p.buildFlags)
+	if err != nil {
+		return err
+	}
+	args = append(args, flags...)
+	args = append(args, pkgVer)
+
+	if err := ensureModule(p); err != nil {
+		return err
+	}
+
+	goget := goCommand(p, args...)
+	absToolsdir, err := filepath.Abs(p.toolsdirName)
+	if err != nil {
+		return fmt.Errorf("error finding absolute path to toolsdir %s: %w", p.toolsdirName, err)
+	}
+	goget.Env = append(os.Environ(), "GOBIN="+absToolsdir)
+	p.logger.Printf("calling \"%s\", with GOBIN=%s", shellquote.Join(goget.Args...), absToolsdir)
+	goget.Stdout = newLogWriter(p.logger)
+	goget.Stderr = newLogWriter(p.logger)
+	if err := goget.Run(); err != nil {
+		return fmt.Errorf("error calling go get: %w", err)
+	}
 	return nil
 }
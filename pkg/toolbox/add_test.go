@@ -0,0 +1,50 @@
+package toolbox
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestTools writes a tools file tracking the given tools into dir, without formatting it with goimports
+func writeTestTools(t *testing.T, dir string, tools ...*tool) {
+	p, err := parseOptions(BasedirOption(dir), GoimportsOption(filepath.Join(dir, "no-goimports")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeTools(tools, p); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAddVerBinaryCollision(t *testing.T) {
+	tests := []struct {
+		name    string
+		pkg     string
+		options []Option
+		tracked string
+	}{
+		{
+			name:    "major version",
+			pkg:     "example.com/hello/v2",
+			tracked: "example.com/hello",
+		},
+		{
+			name:    "custom name",
+			pkg:     "example.com/other",
+			options: []Option{NameOption("hello")},
+			tracked: "example.com/hello",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestTools(t, dir, &tool{Pkg: test.tracked, Version: "v1.0.0"})
+
+			err := AddVer(test.pkg, "", append(test.options, BasedirOption(dir))...)
+			if err == nil || !strings.Contains(err.Error(), "would overwrite the binary of "+test.tracked) || !strings.Contains(err.Error(), "--name") {
+				t.Fatalf("expected %s to be rejected for colliding with %s, got %v", test.pkg, test.tracked, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package toolbox
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// splitAlias splits a tool name of the form "pkg@alias" into its package and alias.  Tools without an alias return an empty alias.  Package paths never contain "@", so everything after the first one is the alias, and an alias containing "@" is left for validName to reject.
+func splitAlias(name string) (string, string) {
+	i := strings.Index(name, "@")
+	if i < 0 {
+		return name, ""
+	}
+	return name[:i], name[i+1:]
+}
+
+// id returns the name that a tool is addressed by, which is its package, followed by "@alias" for side-by-side versions
+func (t *tool) id() string {
+	if t.Alias == "" {
+		return t.Pkg
+	}
+	return t.Pkg + "@" + t.Alias
+}
+
+// aliasQuery returns the version query selected by an alias, so that "1.3" selects the latest v1.3 release
+func aliasQuery(alias string) string {
+	if strings.HasPrefix(alias, "v") {
+		return alias
+	}
+	return "v" + alias
+}
+
+// isolated reports whether a tool is built from its own generated module in the tools directory.  Side-by-side versions are always isolated, so that they don't conflict with the version tracked in go.mod.
+func isolated(t *tool, p *parsedOptions) bool {
+	return p.mode == PerToolMode || (p.mode == SharedMode && t.Alias != "")
+}
+
+// flattenAliases moves side-by-side versions, which are stored inside of the tool they're a version of, into the list of tools
+func flattenAliases(tools []*tool) []*tool {
+	flattened := []*tool{}
+	for _, t := range tools {
+		flattened = append(flattened, t)
+		for _, a := range t.Aliases {
+			a.Pkg = t.Pkg
+			flattened = append(flattened, a)
+		}
+		t.Aliases = nil
+	}
+	return flattened
+}
+
+// nestAliases stores side-by-side versions inside of the tool they're a version of, which is how they're written to the tools file or go.mod.  The given tools are left unmodified.
+func nestAliases(tools []*tool) ([]*tool, error) {
+	nested := []*tool{}
+	byPkg := map[string]*tool{}
+	for _, t := range tools {
+		if t.Alias != "" {
+			continue
+		}
+		n := *t
+		n.Aliases = nil
+		nested = append(nested, &n)
+		byPkg[t.Pkg] = &n
+	}
+	for _, t := range tools {
+		if t.Alias == "" {
+			continue
+		}
+		n, ok := byPkg[t.Pkg]
+		if !ok {
+			return nil, fmt.Errorf("side-by-side version %s is missing its tool %s", t.id(), t.Pkg)
+		}
+		n.Aliases = append(n.Aliases, t)
+	}
+	return nested, nil
+}
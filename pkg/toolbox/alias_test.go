@@ -0,0 +1,99 @@
+package toolbox
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitAlias(t *testing.T) {
+	tests := []struct {
+		name  string
+		pkg   string
+		alias string
+	}{
+		{name: "example.com/hello", pkg: "example.com/hello"},
+		{name: "example.com/hello@1.3", pkg: "example.com/hello", alias: "1.3"},
+		{name: "example.com/hello/v2@2.1", pkg: "example.com/hello/v2", alias: "2.1"},
+		{name: "gopkg.in/x.v1@old", pkg: "gopkg.in/x.v1", alias: "old"},
+		{name: "example.com/hello@1.3@beta", pkg: "example.com/hello", alias: "1.3@beta"},
+		{name: "example.com/hello@", pkg: "example.com/hello"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pkg, alias := splitAlias(test.name)
+			if pkg != test.pkg || alias != test.alias {
+				t.Errorf("expected %s and %s, got %s and %s", test.pkg, test.alias, pkg, alias)
+			}
+		})
+	}
+}
+
+func TestAddVerInvalidAlias(t *testing.T) {
+	for _, name := range []string{"example.com/hello@1.3@beta", "example.com/hello@a/b"} {
+		t.Run(name, func(t *testing.T) {
+			err := AddVer(name, "", BasedirOption(t.TempDir()))
+			if err == nil || !strings.Contains(err.Error(), "invalid alias") {
+				t.Fatalf("expected an invalid alias, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAliasBinaryName(t *testing.T) {
+	tests := []struct {
+		name     string
+		tool     *tool
+		expected string
+	}{
+		{name: "alias", tool: &tool{Pkg: "example.com/hello/v2", Alias: "2.1"}, expected: "hello-2.1"},
+		{name: "custom name", tool: &tool{Pkg: "example.com/hello", Alias: "1.0", Name: "old-hello"}, expected: "old-hello"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if name := strings.TrimSuffix(binaryName(test.tool), ".exe"); name != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, name)
+			}
+		})
+	}
+}
+
+func TestNestAliases(t *testing.T) {
+	hello := &tool{Pkg: "example.com/hello", Version: "v1.1.0"}
+	old := &tool{Pkg: "example.com/hello", Alias: "1.0", Version: "v1.0.1"}
+	other := &tool{Pkg: "example.com/other", Version: "v0.1.0"}
+
+	nested, err := nestAliases([]*tool{hello, old, other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*tool{
+		{Pkg: "example.com/hello", Version: "v1.1.0", Aliases: []*tool{old}},
+		{Pkg: "example.com/other", Version: "v0.1.0"},
+	}
+	if !reflect.DeepEqual(nested, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, nested)
+	}
+	if hello.Aliases != nil {
+		t.Errorf("nestAliases modified the tools it was given")
+	}
+
+	// Aliases lose their package when they're written, and flattening restores it from the tool they're nested in
+	nested[0].Aliases = []*tool{{Alias: "1.0", Version: "v1.0.1"}}
+	flattened := flattenAliases(nested)
+	expected = []*tool{
+		{Pkg: "example.com/hello", Version: "v1.1.0"},
+		{Pkg: "example.com/hello", Alias: "1.0", Version: "v1.0.1"},
+		{Pkg: "example.com/other", Version: "v0.1.0"},
+	}
+	if !reflect.DeepEqual(flattened, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, flattened)
+	}
+}
+
+func TestNestAliasesMissingTool(t *testing.T) {
+	_, err := nestAliases([]*tool{{Pkg: "example.com/hello", Alias: "1.0"}})
+	if err == nil {
+		t.Fatal("expected an error for a side-by-side version without its tool")
+	}
+}
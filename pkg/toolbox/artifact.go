@@ -0,0 +1,521 @@
+package toolbox
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+)
+
+// ArchiveFormat is the format that an artifact is downloaded in
+type ArchiveFormat string
+
+const (
+	// TarGzArchive is a gzipped tarball
+	TarGzArchive ArchiveFormat = "tar.gz"
+	// ZipArchive is a zip file
+	ZipArchive ArchiveFormat = "zip"
+	// RawArchive is the binary itself, with no archive around it
+	RawArchive ArchiveFormat = "raw"
+)
+
+// Artifact is a tool that is downloaded as a prebuilt binary, rather than built with go.  URL and Path are go text/templates, which may use {{.OS}}, {{.Arch}}, and {{.Version}}.  OS and Arch are GOOS and GOARCH, unless renamed to match a project's release names with OSNames and ArchNames.  Path is the location of the binary inside of the archive, and is unused for raw downloads.
+//
+// Every download is checked before it is extracted.  SHA256 holds the expected hash of the download on each platform, keyed by "GOOS/GOARCH".  Checksums is the url of a release's checksums file, in the format written by sha256sum, which is used when no hash is pinned for the platform, and must agree with the pinned hash otherwise.  If Signature is set, it is the url of a signature of the checksums file, which is checked against PublicKey.  Signatures must either be raw ed25519 signatures in base64, or legacy minisign signatures made with "minisign -S -l", as prehashed minisign signatures aren't supported.
+type Artifact struct {
+	Name      string            `json:"name"`
+	Version   string            `json:"version"`
+	URL       string            `json:"url"`
+	Format    ArchiveFormat     `json:"format"`
+	Path      string            `json:"path,omitempty"`
+	OSNames   map[string]string `json:"os_names,omitempty"`
+	ArchNames map[string]string `json:"arch_names,omitempty"`
+	SHA256    map[string]string `json:"sha256,omitempty"`
+	Checksums string            `json:"checksums,omitempty"`
+	Signature string            `json:"signature,omitempty"`
+	PublicKey string            `json:"public_key,omitempty"`
+}
+
+type artifactsfile struct {
+	Artifacts []*Artifact `json:"artifacts"`
+}
+
+type artifactTemplateData struct {
+	OS      string
+	Arch    string
+	Version string
+}
+
+// artifactStamp records what was downloaded for an artifact, in the same way that stamp does for go tools
+type artifactStamp struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	URL     string `json:"url"`
+	SHA256  string `json:"sha256"`
+}
+
+// artifactClient downloads artifacts.  file:// URLs are supported, so that artifacts can be mirrored on a local or network filesystem.
+var artifactClient = func() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
+	return &http.Client{Transport: transport}
+}()
+
+// AddArtifact adds a tool that is downloaded as a prebuilt binary to the artifacts file, and installs it.  The download must match the hash given for the current platform, or the artifact's checksums file.  With TrustDownloadOption, an artifact with neither is accepted, and the hash of the download is recorded, so that every later download is verified against it.
+func AddArtifact(a *Artifact, options ...Option) error {
+	p, err := parseOptions(options...)
+	if err != nil {
+		return fmt.Errorf("error parsing options: %w", err)
+	}
+	if err := a.validate(); err != nil {
+		return err
+	}
+
+	// Tools are installed into the same directory, and one would overwrite the other
+	tools, err := readTools(p)
+	if err != nil {
+		return err
+	}
+	for _, t := range tools {
+		if binaryName(t) == filepath.Base(artifactPath(a, p)) {
+			return fmt.Errorf("artifact %s would overwrite the binary of %s, use a different name", a.Name, t.id())
+		}
+	}
+
+	artifacts, err := readArtifacts(p)
+	if err != nil {
+		return err
+	}
+	var existing *Artifact
+	for i, e := range artifacts {
+		if e.Name == a.Name {
+			existing = e
+			artifacts[i] = a
+			break
+		}
+	}
+	if existing == nil {
+		artifacts = append(artifacts, a)
+	} else if existing.Version == a.Version && existing.URL == a.URL {
+		// Hashes recorded on other platforms are still valid for the same release
+		for platform, sum := range existing.SHA256 {
+			if _, ok := a.SHA256[platform]; !ok {
+				if a.SHA256 == nil {
+					a.SHA256 = map[string]string{}
+				}
+				a.SHA256[platform] = sum
+			}
+		}
+	}
+
+	if a.SHA256[currentPlatform()] == "" && a.Checksums == "" && !p.trustDownload {
+		return fmt.Errorf("no sha256 given for artifact %s on %s, pass the expected hash with --sha256, or a checksums file with --checksums, or use --trust-download to record the hash of this download", a.Name, currentPlatform())
+	}
+
+	s, err := installArtifact(a, a.SHA256[currentPlatform()], p, p.logger)
+	if err != nil {
+		return err
+	}
+	if a.SHA256 == nil {
+		a.SHA256 = map[string]string{}
+	}
+	a.SHA256[currentPlatform()] = s.SHA256
+
+	if err := writeArtifacts(artifacts, p); err != nil {
+		return err
+	}
+	return s.write(p)
+}
+
+// currentPlatform returns the key that artifact hashes are stored under for the platform toolbox is running on
+func currentPlatform() string {
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
+
+func (a *Artifact) validate() error {
+	if !validName.MatchString(a.Name) {
+		return fmt.Errorf("invalid artifact name %s, names may only contain letters, numbers, '.', '_', and '-'", a.Name)
+	}
+	if a.URL == "" {
+		return fmt.Errorf("no url given for artifact %s", a.Name)
+	}
+	if a.Signature != "" && a.Checksums == "" {
+		return fmt.Errorf("artifact %s has a signature, but no checksums file for it to sign", a.Name)
+	}
+	if (a.Signature == "") != (a.PublicKey == "") {
+		return fmt.Errorf("artifact %s needs both a signature and a public key to verify it with", a.Name)
+	}
+	if a.PublicKey != "" {
+		if _, _, err := parsePublicKey(a.PublicKey); err != nil {
+			return fmt.Errorf("error in public key of artifact %s: %w", a.Name, err)
+		}
+	}
+	switch a.Format {
+	case TarGzArchive, ZipArchive:
+		if a.Path == "" {
+			return fmt.Errorf("no path inside of the %s archive given for artifact %s", a.Format, a.Name)
+		}
+	case RawArchive:
+	default:
+		return fmt.Errorf("unknown archive format \"%s\" for artifact %s, must be one of %s, %s, or %s", a.Format, a.Name, TarGzArchive, ZipArchive, RawArchive)
+	}
+	return nil
+}
+
+// render executes one of the artifact's templates for the current platform
+func (a *Artifact) render(text string) (string, error) {
+	data := &artifactTemplateData{
+		OS:      runtime.GOOS,
+		Arch:    runtime.GOARCH,
+		Version: a.Version,
+	}
+	if name, ok := a.OSNames[runtime.GOOS]; ok {
+		data.OS = name
+	}
+	if name, ok := a.ArchNames[runtime.GOARCH]; ok {
+		data.Arch = name
+	}
+
+	t, err := template.New(a.Name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("error parsing template for artifact %s: %w", a.Name, err)
+	}
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, data); err != nil {
+		return "", fmt.Errorf("error executing template for artifact %s: %w", a.Name, err)
+	}
+	return buf.String(), nil
+}
+
+// artifactPath returns the location in the tools directory that an artifact's binary is installed to
+func artifactPath(a *Artifact, p *parsedOptions) string {
+	name := a.Name
+	if runtime.GOOS == "windows" && !strings.HasSuffix(name, ".exe") {
+		name += ".exe"
+	}
+	return filepath.Join(p.toolsdirName, name)
+}
+
+func readArtifacts(p *parsedOptions) ([]*Artifact, error) {
+	bytes, err := ioutil.ReadFile(p.artifactsName)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading artifacts file %s: %w", p.artifactsName, err)
+	}
+	file := &artifactsfile{}
+	if err := json.Unmarshal(bytes, file); err != nil {
+		return nil, fmt.Errorf("error parsing artifacts file %s: %w", p.artifactsName, err)
+	}
+	for _, a := range file.Artifacts {
+		if err := a.validate(); err != nil {
+			return nil, fmt.Errorf("error in artifacts file %s: %w", p.artifactsName, err)
+		}
+	}
+	return file.Artifacts, nil
+}
+
+func writeArtifacts(artifacts []*Artifact, p *parsedOptions) error {
+	j, err := json.MarshalIndent(&artifactsfile{Artifacts: artifacts}, "", "\t")
+	if err != nil {
+		return fmt.Errorf("error marshalling artifacts file: %w", err)
+	}
+	p.logger.Printf("writing artifacts file %s", p.artifactsName)
+	if err := ioutil.WriteFile(p.artifactsName, append(j, '\n'), 0666); err != nil {
+		return fmt.Errorf("error writing artifacts file %s: %w", p.artifactsName, err)
+	}
+	return nil
+}
+
+// syncArtifact downloads an artifact, unless its stamp shows that the same release was already installed
+func syncArtifact(a *Artifact, p *parsedOptions, logger Logger) error {
+	sum := a.SHA256[currentPlatform()]
+	if sum == "" && a.Checksums == "" {
+		return fmt.Errorf("no sha256 recorded for artifact %s on %s, run \"toolbox add-artifact\" on this platform to record one", a.Name, currentPlatform())
+	}
+
+	s, err := newArtifactStamp(a)
+	if err != nil {
+		return err
+	}
+	if !p.force && s.isCurrent(p) {
+		logger.Printf("%s is up to date at %s, skipping", a.Name, a.Version)
+		return nil
+	}
+
+	s, err = installArtifact(a, sum, p, logger)
+	if err != nil {
+		return err
+	}
+	return s.write(p)
+}
+
+// installArtifact downloads an artifact, checks it against sum if one is given, and extracts its binary into the tools directory.  Returns a stamp describing what was installed.
+func installArtifact(a *Artifact, sum string, p *parsedOptions, logger Logger) (*artifactStamp, error) {
+	url, err := a.render(a.URL)
+	if err != nil {
+		return nil, err
+	}
+	member, err := a.render(a.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(p.toolsdirName, 0777); err != nil {
+		return nil, fmt.Errorf("error creating tools directory %s: %w", p.toolsdirName, err)
+	}
+	archive, err := ioutil.TempFile(p.toolsdirName, ".download-")
+	if err != nil {
+		return nil, fmt.Errorf("error creating download file: %w", err)
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	logger.Printf("downloading %s", url)
+	resp, err := artifactClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading artifact %s: %w", a.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error downloading artifact %s from %s: %s", a.Name, url, resp.Status)
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(archive, hash), resp.Body); err != nil {
+		return nil, fmt.Errorf("error downloading artifact %s: %w", a.Name, err)
+	}
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("error writing download of artifact %s: %w", a.Name, err)
+	}
+
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if sum != "" && !strings.EqualFold(sum, actual) {
+		return nil, fmt.Errorf("checksum mismatch for artifact %s downloaded from %s: expected sha256 %s, got %s", a.Name, url, sum, actual)
+	}
+	if a.Checksums != "" {
+		released, err := releaseChecksum(a, url, logger)
+		if err != nil {
+			return nil, err
+		}
+		if released != actual {
+			return nil, fmt.Errorf("checksum mismatch for artifact %s downloaded from %s: release lists sha256 %s, got %s", a.Name, url, released, actual)
+		}
+	}
+
+	dest := artifactPath(a, p)
+	logger.Printf("extracting %s to %s", a.Name, dest)
+	if err := extractArtifact(archive.Name(), a.Format, member, dest); err != nil {
+		return nil, fmt.Errorf("error extracting artifact %s: %w", a.Name, err)
+	}
+	return &artifactStamp{
+		Name:    a.Name,
+		Version: a.Version,
+		URL:     url,
+		SHA256:  actual,
+	}, nil
+}
+
+// extractArtifact copies the binary at member inside of archive to dest.  The binary is written next to dest and renamed into place, so that a failed extraction never leaves a broken tool behind.
+func extractArtifact(archive string, format ArchiveFormat, member, dest string) error {
+	tmp := dest + ".download"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %w", tmp, err)
+	}
+	defer os.Remove(tmp)
+	defer out.Close()
+
+	switch format {
+	case TarGzArchive:
+		err = extractTarGz(archive, member, out)
+	case ZipArchive:
+		err = extractZip(archive, member, out)
+	default:
+		err = copyFile(archive, out)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("error writing %s: %w", tmp, err)
+	}
+	if err := os.Chmod(tmp, 0777); err != nil {
+		return fmt.Errorf("error making %s executable: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		return fmt.Errorf("error moving binary into place at %s: %w", dest, err)
+	}
+	return nil
+}
+
+// archiveMember reports whether name, a path inside of an archive, is the same as member
+func archiveMember(name, member string) bool {
+	return path.Clean(strings.TrimPrefix(name, "./")) == path.Clean(strings.TrimPrefix(member, "./"))
+}
+
+func extractTarGz(archive, member string, out io.Writer) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return fmt.Errorf("error opening archive: %w", err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("error reading gzip archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("error reading tar archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg || !archiveMember(header.Name, member) {
+			continue
+		}
+		if _, err := io.Copy(out, tr); err != nil {
+			return fmt.Errorf("error extracting %s: %w", member, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s not found in archive", member)
+}
+
+func extractZip(archive, member string, out io.Writer) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("error reading zip archive: %w", err)
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !archiveMember(f.Name, member) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("error opening %s in archive: %w", member, err)
+		}
+		defer rc.Close()
+		if _, err := io.Copy(out, rc); err != nil {
+			return fmt.Errorf("error extracting %s: %w", member, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s not found in archive", member)
+}
+
+func copyFile(filename string, out io.Writer) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", filename, err)
+	}
+	defer file.Close()
+	if _, err := io.Copy(out, file); err != nil {
+		return fmt.Errorf("error copying %s: %w", filename, err)
+	}
+	return nil
+}
+
+func artifactStampFile(name string, p *parsedOptions) string {
+	return stampFile("artifact:"+name, p)
+}
+
+// newArtifactStamp returns the stamp that an artifact installed on the current platform should have
+func newArtifactStamp(a *Artifact) (*artifactStamp, error) {
+	url, err := a.render(a.URL)
+	if err != nil {
+		return nil, err
+	}
+	return &artifactStamp{
+		Name:    a.Name,
+		Version: a.Version,
+		URL:     url,
+		SHA256:  a.SHA256[currentPlatform()],
+	}, nil
+}
+
+// isCurrent checks to see if the artifact was already installed from the same download, and that its binary still exists
+func (s *artifactStamp) isCurrent(p *parsedOptions) bool {
+	if _, err := os.Stat(artifactPath(&Artifact{Name: s.Name}, p)); err != nil {
+		return false
+	}
+	bytes, err := ioutil.ReadFile(artifactStampFile(s.Name, p))
+	if err != nil {
+		return false
+	}
+	existing := &artifactStamp{}
+	if err := json.Unmarshal(bytes, existing); err != nil {
+		return false
+	}
+	if s.SHA256 == "" {
+		// Without a pinned hash, the expected hash comes from the release's checksums file, which isn't downloaded for an up to date check
+		existing.SHA256 = ""
+	}
+	return *existing == *s
+}
+
+func (s *artifactStamp) write(p *parsedOptions) error {
+	filename := artifactStampFile(s.Name, p)
+	if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+		return fmt.Errorf("error creating stamp directory: %w", err)
+	}
+	bytes, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("error marshalling stamp for %s: %w", s.Name, err)
+	}
+	if err := ioutil.WriteFile(filename, bytes, 0666); err != nil {
+		return fmt.Errorf("error writing stamp file %s: %w", filename, err)
+	}
+	return nil
+}
+
+// removeArtifact stops tracking the artifact with the given name, and deletes its binary.  Returns false if there is no such artifact.
+func removeArtifact(name string, p *parsedOptions) (bool, error) {
+	artifacts, err := readArtifacts(p)
+	if err != nil {
+		return false, err
+	}
+	remaining := []*Artifact{}
+	var removed *Artifact
+	for _, a := range artifacts {
+		if a.Name == name {
+			removed = a
+		} else {
+			remaining = append(remaining, a)
+		}
+	}
+	if removed == nil {
+		return false, nil
+	}
+
+	if err := writeArtifacts(remaining, p); err != nil {
+		return false, err
+	}
+	binary := artifactPath(removed, p)
+	p.logger.Printf("removing file %s", binary)
+	if err := os.Remove(binary); err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("error deleting artifact binary: %w", err)
+	}
+	if err := os.Remove(artifactStampFile(name, p)); err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("error removing stamp for %s: %w", name, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,310 @@
+package toolbox
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+var artifactBinary = []byte("#!/bin/sh\necho hello\n")
+
+func tarGzArtifact(t *testing.T, member string, contents []byte) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{Name: member, Mode: 0755, Size: int64(len(contents)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(contents); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipArtifact(t *testing.T, member string, contents []byte) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	w, err := zw.Create(member)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(contents); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// serveArtifacts serves the given files, and a checksums file listing every one of them, at /checksums.txt
+func serveArtifacts(t *testing.T, files map[string][]byte) *httptest.Server {
+	checksums := &strings.Builder{}
+	for name, contents := range files {
+		fmt.Fprintf(checksums, "%s  %s\n", sha256Hex(contents), name)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		if name == "checksums.txt" {
+			w.Write([]byte(checksums.String()))
+			return
+		}
+		contents, ok := files[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(contents)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAddArtifact(t *testing.T) {
+	files := map[string][]byte{
+		"tool.tar.gz": tarGzArtifact(t, "tool-1.0/bin/tool", artifactBinary),
+		"tool.zip":    zipArtifact(t, "bin/tool", artifactBinary),
+		"tool":        artifactBinary,
+	}
+	server := serveArtifacts(t, files)
+
+	pinned := func(file string) map[string]string {
+		return map[string]string{currentPlatform(): sha256Hex(files[file])}
+	}
+	tests := []struct {
+		name     string
+		artifact *Artifact
+		options  []Option
+	}{
+		{
+			name: "tar.gz",
+			artifact: &Artifact{
+				Name:    "tool",
+				Version: "1.0",
+				URL:     server.URL + "/tool.tar.gz",
+				Format:  TarGzArchive,
+				Path:    "tool-{{.Version}}/bin/tool",
+				SHA256:  pinned("tool.tar.gz"),
+			},
+		},
+		{
+			name: "zip",
+			artifact: &Artifact{
+				Name:    "tool",
+				Version: "1.0",
+				URL:     server.URL + "/tool.zip",
+				Format:  ZipArchive,
+				Path:    "bin/tool",
+				SHA256:  pinned("tool.zip"),
+			},
+		},
+		{
+			name: "raw",
+			artifact: &Artifact{
+				Name:    "tool",
+				Version: "1.0",
+				URL:     server.URL + "/tool",
+				Format:  RawArchive,
+				SHA256:  pinned("tool"),
+			},
+		},
+		{
+			name: "trusted download",
+			artifact: &Artifact{
+				Name:    "tool",
+				Version: "1.0",
+				URL:     server.URL + "/tool",
+				Format:  RawArchive,
+			},
+			options: []Option{TrustDownloadOption(true)},
+		},
+		{
+			name: "checksums file",
+			artifact: &Artifact{
+				Name:      "tool",
+				Version:   "1.0",
+				URL:       server.URL + "/tool.zip",
+				Format:    ZipArchive,
+				Path:      "bin/tool",
+				Checksums: server.URL + "/checksums.txt",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := AddArtifact(test.artifact, append(test.options, BasedirOption(dir))...); err != nil {
+				t.Fatalf("error adding artifact: %v", err)
+			}
+
+			p, err := parseOptions(BasedirOption(dir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			binary, err := ioutil.ReadFile(artifactPath(test.artifact, p))
+			if err != nil {
+				t.Fatalf("error reading installed artifact: %v", err)
+			}
+			if !bytes.Equal(binary, artifactBinary) {
+				t.Errorf("installed artifact is %q, expected %q", binary, artifactBinary)
+			}
+
+			artifacts, err := readArtifacts(p)
+			if err != nil {
+				t.Fatalf("error reading artifacts file: %v", err)
+			}
+			if len(artifacts) != 1 {
+				t.Fatalf("expected 1 artifact to be recorded, got %d", len(artifacts))
+			}
+			download, _ := test.artifact.render(test.artifact.URL)
+			expected := sha256Hex(files[strings.TrimPrefix(download, server.URL+"/")])
+			if sum := artifacts[0].SHA256[currentPlatform()]; sum != expected {
+				t.Errorf("recorded sha256 %s, expected %s", sum, expected)
+			}
+		})
+	}
+}
+
+func TestAddArtifactChecksumMismatch(t *testing.T) {
+	server := serveArtifacts(t, map[string][]byte{"tool": artifactBinary})
+
+	dir := t.TempDir()
+	a := &Artifact{
+		Name:    "tool",
+		Version: "1.0",
+		URL:     server.URL + "/tool",
+		Format:  RawArchive,
+		SHA256:  map[string]string{currentPlatform(): sha256Hex([]byte("something else"))},
+	}
+	err := AddArtifact(a, BasedirOption(dir))
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+
+	p, err := parseOptions(BasedirOption(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(artifactPath(a, p)); !os.IsNotExist(err) {
+		t.Errorf("artifact was installed despite the checksum mismatch")
+	}
+	if _, err := os.Stat(p.artifactsName); !os.IsNotExist(err) {
+		t.Errorf("artifact was recorded despite the checksum mismatch")
+	}
+}
+
+func TestAddArtifactWithoutHash(t *testing.T) {
+	server := serveArtifacts(t, map[string][]byte{"tool": artifactBinary})
+
+	dir := t.TempDir()
+	a := &Artifact{
+		Name:    "tool",
+		Version: "1.0",
+		URL:     server.URL + "/tool",
+		Format:  RawArchive,
+	}
+	err := AddArtifact(a, BasedirOption(dir))
+	if err == nil || !strings.Contains(err.Error(), "no sha256 given") {
+		t.Fatalf("expected the artifact to be rejected without a hash, got %v", err)
+	}
+	p, err := parseOptions(BasedirOption(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(artifactPath(a, p)); !os.IsNotExist(err) {
+		t.Errorf("artifact was installed without a hash")
+	}
+}
+
+func TestSyncArtifactChecksumMismatch(t *testing.T) {
+	files := map[string][]byte{"tool": artifactBinary}
+	server := serveArtifacts(t, files)
+
+	dir := t.TempDir()
+	writeTestModule(t, dir, map[string]string{"go.mod": "module example.com/project\n"})
+	a := &Artifact{
+		Name:    "tool",
+		Version: "1.0",
+		URL:     server.URL + "/tool",
+		Format:  RawArchive,
+		SHA256:  map[string]string{currentPlatform(): sha256Hex(artifactBinary)},
+	}
+	if err := AddArtifact(a, BasedirOption(dir)); err != nil {
+		t.Fatalf("error adding artifact: %v", err)
+	}
+	if err := Sync(BasedirOption(dir), ForceOption(true)); err != nil {
+		t.Fatalf("error syncing unchanged artifact: %v", err)
+	}
+
+	// The release is replaced with something else, which sync must refuse to install
+	files["tool"] = []byte("#!/bin/sh\necho tampered\n")
+	err := Sync(BasedirOption(dir), ForceOption(true))
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+	p, err := parseOptions(BasedirOption(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary, err := ioutil.ReadFile(artifactPath(a, p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(binary, artifactBinary) {
+		t.Errorf("installed artifact was replaced with %q despite the checksum mismatch", binary)
+	}
+}
+
+func TestAddArtifactToolCollision(t *testing.T) {
+	server := serveArtifacts(t, map[string][]byte{"hello": artifactBinary})
+
+	dir := t.TempDir()
+	writeTestTools(t, dir, &tool{Pkg: "example.com/hello", Version: "v1.0.0"})
+	a := &Artifact{
+		Name:    "hello",
+		Version: "1.0",
+		URL:     server.URL + "/hello",
+		Format:  RawArchive,
+		SHA256:  map[string]string{currentPlatform(): sha256Hex(artifactBinary)},
+	}
+	err := AddArtifact(a, BasedirOption(dir))
+	if err == nil || !strings.Contains(err.Error(), "would overwrite the binary of example.com/hello") {
+		t.Fatalf("expected the artifact to be rejected for colliding with a tool, got %v", err)
+	}
+}
+
+func TestAddVerArtifactCollision(t *testing.T) {
+	dir := t.TempDir()
+	p, err := parseOptions(BasedirOption(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeArtifacts([]*Artifact{{Name: "hello", Version: "1.0", URL: "https://example.com/hello", Format: RawArchive}}, p); err != nil {
+		t.Fatal(err)
+	}
+	err = AddVer("example.com/hello", "", BasedirOption(dir))
+	if err == nil || !strings.Contains(err.Error(), "would overwrite artifact hello") || !strings.Contains(err.Error(), "--name") {
+		t.Fatalf("expected the tool to be rejected for colliding with an artifact, got %v", err)
+	}
+}
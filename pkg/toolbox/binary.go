@@ -0,0 +1,86 @@
+package toolbox
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// defaultBinaryName returns the name that "go install" gives the binary built from pkg.  Like go, a major version suffix such as "/v2" is skipped, so that "github.com/foo/bar/v2" is installed as "bar".
+func defaultBinaryName(pkg string) string {
+	_, elem := path.Split(pkg)
+	if elem != pkg && isVersionElement(elem) {
+		_, elem = path.Split(path.Dir(pkg))
+	}
+	return elem
+}
+
+// isVersionElement reports whether elem is a major version suffix, such as "v2"
+func isVersionElement(elem string) bool {
+	if len(elem) < 2 || elem[0] != 'v' || elem[1] < '1' || elem[1] > '9' {
+		return false
+	}
+	for _, c := range elem[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return elem != "v1"
+}
+
+// binaryName returns the file name of a tool's binary, which is its custom name if one was given.  Side-by-side versions default to the usual name followed by their alias, such as "protoc-gen-go-1.3".
+func binaryName(t *tool) string {
+	name := t.Name
+	if name == "" {
+		name = defaultBinaryName(t.Pkg)
+		if t.Alias != "" {
+			name += "-" + t.Alias
+		}
+	}
+	if runtime.GOOS == "windows" && !strings.HasSuffix(name, ".exe") {
+		name += ".exe"
+	}
+	return name
+}
+
+// binaryPath returns the location in the tools directory that a tool's binary is installed to
+func binaryPath(t *tool, p *parsedOptions) string {
+	return filepath.Join(p.toolsdirName, binaryName(t))
+}
+
+// installGobin returns the GOBIN that a tool should be installed with.  Tools with a custom name, including side-by-side versions, are installed to a temporary directory, which must be moved into place with finishInstall, and then removed by the caller.
+func installGobin(t *tool, p *parsedOptions) (string, error) {
+	absToolsdir, err := filepath.Abs(p.toolsdirName)
+	if err != nil {
+		return "", fmt.Errorf("error finding absolute path to toolsdir %s: %w", p.toolsdirName, err)
+	}
+	if binaryName(t) == binaryName(&tool{Pkg: t.Pkg}) {
+		return absToolsdir, nil
+	}
+
+	// The temporary directory is kept in the tools directory, so that the binary can be renamed into place without crossing filesystems
+	if err := os.MkdirAll(absToolsdir, 0777); err != nil {
+		return "", fmt.Errorf("error creating tools directory %s: %w", absToolsdir, err)
+	}
+	tmp, err := ioutil.TempDir(absToolsdir, ".install-")
+	if err != nil {
+		return "", fmt.Errorf("error creating temporary install directory: %w", err)
+	}
+	return tmp, nil
+}
+
+// finishInstall moves a tool installed under a different name from the temporary gobin into the tools directory
+func finishInstall(t *tool, gobin string, p *parsedOptions) error {
+	if binaryName(t) == binaryName(&tool{Pkg: t.Pkg}) {
+		return nil
+	}
+	installed := filepath.Join(gobin, binaryName(&tool{Pkg: t.Pkg}))
+	if err := os.Rename(installed, binaryPath(t, p)); err != nil {
+		return fmt.Errorf("error moving %s into place as %s: %w", t.Pkg, binaryName(t), err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package toolbox
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefaultBinaryName(t *testing.T) {
+	tests := []struct {
+		pkg      string
+		expected string
+	}{
+		{pkg: "example.com/hello", expected: "hello"},
+		{pkg: "example.com/hello/v2", expected: "hello"},
+		{pkg: "example.com/hello/v10", expected: "hello"},
+		{pkg: "example.com/pkg/cmd/v2", expected: "cmd"},
+		// v0 and v1 are never major version suffixes, so go keeps them
+		{pkg: "example.com/hello/v1", expected: "v1"},
+		{pkg: "example.com/hello/v0", expected: "v0"},
+		{pkg: "example.com/hello/v2beta", expected: "v2beta"},
+		// gopkg.in versions are part of the last element, and aren't skipped
+		{pkg: "gopkg.in/x.v1", expected: "x.v1"},
+		{pkg: "v2", expected: "v2"},
+	}
+	for _, test := range tests {
+		t.Run(test.pkg, func(t *testing.T) {
+			if name := defaultBinaryName(test.pkg); name != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, name)
+			}
+		})
+	}
+}
+
+func TestBinaryName(t *testing.T) {
+	tests := []struct {
+		name     string
+		tool     *tool
+		expected string
+	}{
+		{name: "default", tool: &tool{Pkg: "example.com/hello/v2"}, expected: "hello"},
+		{name: "custom", tool: &tool{Pkg: "example.com/hello", Name: "hi"}, expected: "hi"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if name := strings.TrimSuffix(binaryName(test.tool), ".exe"); name != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, name)
+			}
+		})
+	}
+}
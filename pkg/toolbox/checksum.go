@@ -0,0 +1,173 @@
+package toolbox
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// bsdChecksumLine matches the "SHA256 (file) = hash" lines written by "shasum --tag" and BSD's sha256
+var bsdChecksumLine = regexp.MustCompile(`^SHA256 \((.+)\) = ([0-9a-fA-F]{64})$`)
+
+// minisignAlgorithm is the signature algorithm of minisign keys and signatures that sign the message directly.  Prehashed signatures, which minisign writes by default, use BLAKE2b and are not supported.
+const minisignAlgorithm = "Ed"
+
+// releaseChecksum downloads an artifact's checksums file, verifies its signature if the artifact has a public key, and returns the sha256 listed for the file at artifactURL
+func releaseChecksum(a *Artifact, artifactURL string, logger Logger) (string, error) {
+	checksumsURL, err := a.render(a.Checksums)
+	if err != nil {
+		return "", err
+	}
+	checksums, err := fetch(checksumsURL, logger)
+	if err != nil {
+		return "", fmt.Errorf("error downloading checksums for artifact %s: %w", a.Name, err)
+	}
+
+	if a.Signature != "" {
+		signatureURL, err := a.render(a.Signature)
+		if err != nil {
+			return "", err
+		}
+		signature, err := fetch(signatureURL, logger)
+		if err != nil {
+			return "", fmt.Errorf("error downloading checksums signature for artifact %s: %w", a.Name, err)
+		}
+		if err := verifySignature(checksums, signature, a.PublicKey); err != nil {
+			return "", fmt.Errorf("error verifying checksums of artifact %s from %s: %w", a.Name, checksumsURL, err)
+		}
+		logger.Printf("verified signature of %s", checksumsURL)
+	}
+
+	u, err := url.Parse(artifactURL)
+	if err != nil {
+		return "", fmt.Errorf("error parsing url %s: %w", artifactURL, err)
+	}
+	filename := path.Base(u.Path)
+	sum, err := findChecksum(checksums, filename)
+	if err != nil {
+		return "", fmt.Errorf("error reading checksums of artifact %s from %s: %w", a.Name, checksumsURL, err)
+	}
+	return sum, nil
+}
+
+// fetch downloads a small file, such as a checksums file or signature, into memory
+func fetch(fetchURL string, logger Logger) ([]byte, error) {
+	logger.Printf("downloading %s", fetchURL)
+	resp, err := artifactClient.Get(fetchURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error downloading %s: %s", fetchURL, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading %s: %w", fetchURL, err)
+	}
+	return body, nil
+}
+
+// findChecksum finds the sha256 of filename in a checksums file, written in either the format of sha256sum or "shasum --tag"
+func findChecksum(checksums []byte, filename string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if m := bsdChecksumLine.FindStringSubmatch(line); m != nil {
+			if path.Base(m[1]) == filename {
+				return strings.ToLower(m[2]), nil
+			}
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 || len(fields[0]) != 64 {
+			continue
+		}
+		// A leading * marks a file hashed in binary mode
+		if path.Base(strings.TrimPrefix(fields[1], "*")) == filename {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no sha256 listed for %s", filename)
+}
+
+// verifySignature checks an ed25519 signature over message.  The public key and signature may either be the plain base64 encoded key and signature, or be in minisign's format, as long as the signature is a legacy one that signs message directly.
+func verifySignature(message, signature []byte, publicKey string) error {
+	keyID, key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(signature)), "\n")
+	if len(lines) == 1 {
+		sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[0]))
+		if err != nil || len(sig) != ed25519.SignatureSize {
+			return fmt.Errorf("invalid signature")
+		}
+		if !ed25519.Verify(key, message, sig) {
+			return fmt.Errorf("signature does not match")
+		}
+		return nil
+	}
+
+	// minisign signatures are an untrusted comment, the signature, a trusted comment, and a signature over the signature and trusted comment
+	if len(lines) != 4 {
+		return fmt.Errorf("invalid minisign signature")
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("invalid minisign signature")
+	}
+	if string(sig[:2]) != minisignAlgorithm {
+		return fmt.Errorf("unsupported minisign signature algorithm %s, sign with \"minisign -S -l\" instead", string(sig[:2]))
+	}
+	if keyID != nil && !bytes.Equal(sig[2:10], keyID) {
+		return fmt.Errorf("signature was made with a different key")
+	}
+	if !ed25519.Verify(key, message, sig[10:]) {
+		return fmt.Errorf("signature does not match")
+	}
+
+	trustedComment := strings.TrimPrefix(strings.TrimSpace(lines[2]), "trusted comment: ")
+	globalSig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		return fmt.Errorf("invalid minisign signature")
+	}
+	if !ed25519.Verify(key, append(append([]byte{}, sig[10:]...), trustedComment...), globalSig) {
+		return fmt.Errorf("trusted comment signature does not match")
+	}
+	return nil
+}
+
+// parsePublicKey decodes an ed25519 public key.  minisign keys also include a key id, which is returned so that it can be matched against signatures.
+func parsePublicKey(publicKey string) ([]byte, ed25519.PublicKey, error) {
+	lines := strings.Split(strings.TrimSpace(publicKey), "\n")
+	// minisign .pub files start with an untrusted comment
+	encoded := strings.TrimSpace(lines[len(lines)-1])
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	switch len(decoded) {
+	case ed25519.PublicKeySize:
+		return nil, ed25519.PublicKey(decoded), nil
+	case 2 + 8 + ed25519.PublicKeySize:
+		if string(decoded[:2]) != minisignAlgorithm {
+			return nil, nil, fmt.Errorf("unsupported minisign key algorithm %s", string(decoded[:2]))
+		}
+		return decoded[2:10], ed25519.PublicKey(decoded[10:]), nil
+	default:
+		return nil, nil, fmt.Errorf("invalid public key")
+	}
+}
@@ -0,0 +1,124 @@
+package toolbox
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+var (
+	testKey      = ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	otherTestKey = ed25519.NewKeyFromSeed(bytes.Repeat([]byte{2}, ed25519.SeedSize))
+	testKeyID    = []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	testMessage  = []byte("0123  tool.tar.gz\n")
+)
+
+func rawPublicKey(key ed25519.PrivateKey) string {
+	return base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+}
+
+func minisignPublicKey(key ed25519.PrivateKey, keyID []byte) string {
+	encoded := append(append([]byte("Ed"), keyID...), key.Public().(ed25519.PublicKey)...)
+	return "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(encoded) + "\n"
+}
+
+// minisignSignature signs message the way "minisign -S -l" does, but with the given algorithm.  Real prehashed "ED" signatures sign a BLAKE2b hash of the message, but those must be rejected by their algorithm alone, so the message is always signed directly.
+func minisignSignature(key ed25519.PrivateKey, keyID []byte, algorithm, trustedComment string, message []byte) string {
+	sig := ed25519.Sign(key, message)
+	encoded := append(append([]byte(algorithm), keyID...), sig...)
+	globalSig := ed25519.Sign(key, append(append([]byte{}, sig...), trustedComment...))
+	return "untrusted comment: signature from minisign secret key\n" +
+		base64.StdEncoding.EncodeToString(encoded) + "\n" +
+		"trusted comment: " + trustedComment + "\n" +
+		base64.StdEncoding.EncodeToString(globalSig) + "\n"
+}
+
+func TestVerifySignature(t *testing.T) {
+	rawSig := base64.StdEncoding.EncodeToString(ed25519.Sign(testKey, testMessage))
+	goodMinisign := minisignSignature(testKey, testKeyID, "Ed", "timestamp:1", testMessage)
+	minisignLines := strings.Split(goodMinisign, "\n")
+	tamperedComment := strings.Join([]string{minisignLines[0], minisignLines[1], "trusted comment: timestamp:2", minisignLines[3]}, "\n")
+
+	tests := []struct {
+		name      string
+		signature string
+		publicKey string
+		err       string
+	}{
+		{name: "raw", signature: rawSig, publicKey: rawPublicKey(testKey)},
+		{name: "raw with newline", signature: rawSig + "\n", publicKey: rawPublicKey(testKey)},
+		{name: "raw wrong key", signature: rawSig, publicKey: rawPublicKey(otherTestKey), err: "signature does not match"},
+		{name: "raw other message", signature: base64.StdEncoding.EncodeToString(ed25519.Sign(testKey, []byte("other"))), publicKey: rawPublicKey(testKey), err: "signature does not match"},
+		{name: "raw not base64", signature: "not a signature!", publicKey: rawPublicKey(testKey), err: "invalid signature"},
+		{name: "raw too short", signature: base64.StdEncoding.EncodeToString([]byte("short")), publicKey: rawPublicKey(testKey), err: "invalid signature"},
+		{name: "minisign", signature: goodMinisign, publicKey: minisignPublicKey(testKey, testKeyID)},
+		{name: "minisign with raw key", signature: goodMinisign, publicKey: rawPublicKey(testKey)},
+		{name: "minisign wrong key id", signature: goodMinisign, publicKey: minisignPublicKey(testKey, []byte{8, 7, 6, 5, 4, 3, 2, 1}), err: "different key"},
+		{name: "minisign wrong key", signature: goodMinisign, publicKey: minisignPublicKey(otherTestKey, testKeyID), err: "signature does not match"},
+		{name: "minisign other message", signature: minisignSignature(testKey, testKeyID, "Ed", "timestamp:1", []byte("other")), publicKey: minisignPublicKey(testKey, testKeyID), err: "signature does not match"},
+		{name: "minisign tampered trusted comment", signature: tamperedComment, publicKey: minisignPublicKey(testKey, testKeyID), err: "trusted comment signature does not match"},
+		{name: "minisign prehashed", signature: minisignSignature(testKey, testKeyID, "ED", "timestamp:1", testMessage), publicKey: minisignPublicKey(testKey, testKeyID), err: "unsupported minisign signature algorithm ED"},
+		{name: "three lines", signature: strings.Join(minisignLines[:3], "\n"), publicKey: minisignPublicKey(testKey, testKeyID), err: "invalid minisign signature"},
+		{name: "two lines", signature: strings.Join(minisignLines[:2], "\n"), publicKey: minisignPublicKey(testKey, testKeyID), err: "invalid minisign signature"},
+		{name: "minisign signature too short", signature: strings.Join([]string{minisignLines[0], rawSig, minisignLines[2], minisignLines[3]}, "\n"), publicKey: minisignPublicKey(testKey, testKeyID), err: "invalid minisign signature"},
+		{name: "minisign global signature invalid", signature: strings.Join([]string{minisignLines[0], minisignLines[1], minisignLines[2], "nope"}, "\n"), publicKey: minisignPublicKey(testKey, testKeyID), err: "invalid minisign signature"},
+		{name: "invalid key", signature: rawSig, publicKey: "not a key!", err: "invalid public key"},
+		{name: "prehashed key", signature: goodMinisign, publicKey: base64.StdEncoding.EncodeToString(append(append([]byte("ED"), testKeyID...), testKey.Public().(ed25519.PublicKey)...)), err: "unsupported minisign key algorithm"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := verifySignature(testMessage, []byte(test.signature), test.publicKey)
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("expected the signature to verify, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected an error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestFindChecksum(t *testing.T) {
+	sum := strings.Repeat("ab", 32)
+	other := strings.Repeat("cd", 32)
+	tests := []struct {
+		name      string
+		checksums string
+		filename  string
+		expected  string
+	}{
+		{name: "sha256sum", checksums: other + "  tool_linux.zip\n" + sum + "  tool_linux.tar.gz\n", filename: "tool_linux.tar.gz", expected: sum},
+		{name: "binary mode", checksums: sum + " *tool_linux.tar.gz\n", filename: "tool_linux.tar.gz", expected: sum},
+		{name: "directory", checksums: sum + "  dist/tool_linux.tar.gz\n", filename: "tool_linux.tar.gz", expected: sum},
+		{name: "uppercase", checksums: strings.ToUpper(sum) + "  tool_linux.tar.gz\n", filename: "tool_linux.tar.gz", expected: sum},
+		{name: "crlf", checksums: sum + "  tool_linux.tar.gz\r\n", filename: "tool_linux.tar.gz", expected: sum},
+		{name: "bsd", checksums: "SHA256 (tool_linux.zip) = " + other + "\nSHA256 (tool_linux.tar.gz) = " + sum + "\n", filename: "tool_linux.tar.gz", expected: sum},
+		{name: "bsd with spaces", checksums: "SHA256 (my tool.tar.gz) = " + sum + "\n", filename: "my tool.tar.gz", expected: sum},
+		{name: "prefix of another file", checksums: other + "  tool_linux.tar.gz.sig\n" + sum + "  tool_linux.tar.gz\n", filename: "tool_linux.tar.gz", expected: sum},
+		{name: "missing", checksums: other + "  tool_linux.zip\n", filename: "tool_linux.tar.gz"},
+		{name: "short hash", checksums: "abcd  tool_linux.tar.gz\n", filename: "tool_linux.tar.gz"},
+		{name: "bsd other algorithm", checksums: "SHA512 (tool_linux.tar.gz) = " + sum + "\n", filename: "tool_linux.tar.gz"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			found, err := findChecksum([]byte(test.checksums), test.filename)
+			if test.expected == "" {
+				if err == nil {
+					t.Fatalf("expected no checksum to be found, got %s", found)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, found)
+			}
+		})
+	}
+}
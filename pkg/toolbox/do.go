@@ -13,7 +13,7 @@ func Do(command ...string) error {
 	return DoOpts(command)
 }
 
-// Do runs the given command with the given options, using a vendored tool if applicable
+// Do runs the given command with the given options, using a vendored tool if applicable.  The command may also be the package path of a tool.
 func DoOpts(command []string, options ...Option) error {
 	if len(command) < 1 {
 		return nil
@@ -48,7 +48,41 @@ func CommandOpts(command string, args []string, options ...Option) (*exec.Cmd, e
 		return nil, fmt.Errorf("error finding absolute path to toolsdir %s: %w", p.toolsdirName, err)
 	}
 
+	// A broken tools or artifacts file shouldn't stop every command from running, so those fall back to the PATH
+	tools, err := readTools(p)
+	if err != nil {
+		p.logger.Printf("ignoring tracked tools: %v", err)
+		tools = nil
+	}
+	artifacts, err := readArtifacts(p)
+	if err != nil {
+		p.logger.Printf("ignoring tracked artifacts: %v", err)
+		artifacts = nil
+	}
+
 	doCommand := command
+	if id, binary, ok := resolveCommand(command, tools, artifacts, p); ok {
+		// Tools may also be run by package path, which is resolved to whatever the binary is named
+		command = filepath.Base(binary)
+		doCommand = command
+		if p.autoSync {
+			current, err := isInstalled(id, tools, artifacts, p)
+			if err != nil {
+				return nil, err
+			}
+			if !current {
+				p.logger.Printf("%s is missing or out of date, syncing", id)
+				if err := syncTools(p, map[string]bool{id: true}); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if p.strict || p.isolated {
+			if _, err := os.Stat(binary); err != nil {
+				return nil, fmt.Errorf("%s is tracked by toolbox but not installed, run \"toolbox sync\" to install it", id)
+			}
+		}
+	}
 	if !strings.Contains(command, string(filepath.Separator)) {
 		potentialCommand := filepath.Join(absToolsdir, command)
 		if _, err := os.Stat(potentialCommand); err == nil {
@@ -56,13 +90,82 @@ func CommandOpts(command string, args []string, options ...Option) (*exec.Cmd, e
 		}
 	}
 
-	cmd := exec.Command(doCommand, args...)
+	vars, err := toolEnv(tools, p)
+	if err != nil {
+		return nil, err
+	}
+	if p.isolated {
+		path, err := isolatedPath(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range vars {
+			if v.name == "PATH" {
+				v.value = path
+				v.prepend = false
+			}
+		}
+		// exec.Command searches the PATH of this process, which the command must not fall back to
+		if !strings.Contains(command, string(filepath.Separator)) {
+			doCommand, err = lookIsolatedPath(command, path)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
 
-	// GOBIN is set so that tools that install other tools still prefer the tool directory
-	cmd.Env = append(os.Environ(),
-		"GOBIN="+absToolsdir,
-		"PATH="+absToolsdir+string(filepath.ListSeparator)+os.Getenv("PATH"),
-	)
+	cmd := exec.Command(doCommand, args...)
+	cmd.Env = environ(vars)
 
 	return cmd, nil
 }
+
+// resolveCommand finds the tool or artifact that command refers to, either by its binary name or by its package path.  Returns the id of the tool or name of the artifact, and the path to its binary.
+func resolveCommand(command string, tools []*tool, artifacts []*Artifact, p *parsedOptions) (string, string, bool) {
+	for _, t := range tools {
+		binary := binaryPath(t, p)
+		if t.id() == command || filepath.Base(binary) == command || strings.TrimSuffix(filepath.Base(binary), ".exe") == command {
+			return t.id(), binary, true
+		}
+	}
+	for _, a := range artifacts {
+		binary := artifactPath(a, p)
+		if a.Name == command {
+			return a.Name, binary, true
+		}
+	}
+	return "", "", false
+}
+
+// isInstalled reports whether the tool or artifact with the given id is installed, and was built or downloaded from what is tracked now
+func isInstalled(id string, tools []*tool, artifacts []*Artifact, p *parsedOptions) (bool, error) {
+	for _, t := range tools {
+		if t.id() != id {
+			continue
+		}
+		parseFile, err := readTrackingModfile(p)
+		if err != nil {
+			return false, err
+		}
+		goVer, err := goVersion(p)
+		if err != nil {
+			return false, err
+		}
+		s, err := newStamp(t, parseFile, goVer, p)
+		if err != nil {
+			return false, err
+		}
+		return s.isCurrent(t, p), nil
+	}
+	for _, a := range artifacts {
+		if a.Name != id {
+			continue
+		}
+		s, err := newArtifactStamp(a)
+		if err != nil {
+			return false, err
+		}
+		return s.isCurrent(p), nil
+	}
+	return false, nil
+}
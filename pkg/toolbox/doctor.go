@@ -0,0 +1,288 @@
+package toolbox
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"go/build/constraint"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// CheckStatus is the outcome of a single doctor check
+type CheckStatus string
+
+const (
+	// CheckPass means nothing is wrong
+	CheckPass CheckStatus = "pass"
+	// CheckWarn means something may cause problems, but toolbox can still work
+	CheckWarn CheckStatus = "warn"
+	// CheckFail means toolbox will not work correctly until the problem is fixed
+	CheckFail CheckStatus = "fail"
+)
+
+// Check is the result of a single diagnostic performed by Doctor.  Fix suggests how to resolve a warning or failure.
+type Check struct {
+	Name    string      `json:"name"`
+	Status  CheckStatus `json:"status"`
+	Message string      `json:"message"`
+	Fix     string      `json:"fix,omitempty"`
+}
+
+// Doctor diagnoses common problems with a project's tool setup, such as missing binaries, a missing go module, or a tools file with the wrong build tag.  Problems are reported as checks rather than errors, so that every problem is found in a single run.
+func Doctor(options ...Option) ([]*Check, error) {
+	checks := []*Check{}
+	p := applyOptions(options...)
+
+	goCheck := checkBinary("go", p.goBinary, CheckFail, "install go, or point toolbox at it with --go")
+	checks = append(checks, goCheck)
+	checks = append(checks, checkBinary("goimports", p.goimportsBinary, CheckWarn, "install it with \"go install golang.org/x/tools/cmd/goimports@latest\", so that the tools file stays formatted"))
+	if goCheck.Status == CheckFail {
+		return checks, nil
+	}
+	gomodCheck := checkGomod(p)
+	checks = append(checks, gomodCheck)
+	if gomodCheck.Status == CheckFail {
+		return checks, nil
+	}
+
+	p, err := parseOptions(options...)
+	if err != nil {
+		checks = append(checks, &Check{
+			Name:    "options",
+			Status:  CheckFail,
+			Message: err.Error(),
+			Fix:     "fix the configuration or commandline flags",
+		})
+		return checks, nil
+	}
+
+	tools, err := readTools(p)
+	if err != nil {
+		checks = append(checks, &Check{
+			Name:    "tools",
+			Status:  CheckFail,
+			Message: err.Error(),
+			Fix:     "fix or remove the tools file, and re-add your tools",
+		})
+		return checks, nil
+	}
+
+	if p.backend == ToolsfileBackend {
+		checks = append(checks, checkBuildTag(p))
+	}
+	checks = append(checks, checkTracked(tools, p)...)
+	checks = append(checks, checkBinaries(tools, p)...)
+	checks = append(checks, checkGitignore(p))
+	return checks, nil
+}
+
+func checkBinary(name, binary string, status CheckStatus, fix string) *Check {
+	found, err := exec.LookPath(binary)
+	if err != nil {
+		return &Check{
+			Name:    name,
+			Status:  status,
+			Message: fmt.Sprintf("%s not found on PATH", binary),
+			Fix:     fix,
+		}
+	}
+	return &Check{
+		Name:    name,
+		Status:  CheckPass,
+		Message: found,
+	}
+}
+
+func checkGomod(p *parsedOptions) *Check {
+	gomod, err := goModPath(p.goBinary)
+	if err != nil {
+		return &Check{
+			Name:    "go module",
+			Status:  CheckFail,
+			Message: err.Error(),
+			Fix:     "make sure \"go env GOMOD\" works",
+		}
+	}
+	if gomod != "" {
+		return &Check{
+			Name:    "go module",
+			Status:  CheckPass,
+			Message: gomod,
+		}
+	}
+
+	// A base directory is only required to be a module if tools are tracked there
+	status := CheckFail
+	if p.basedirName != "" && (p.mode != SharedMode || p.toolsmoduleName != "") {
+		status = CheckWarn
+	}
+	return &Check{
+		Name:    "go module",
+		Status:  status,
+		Message: "no go.mod found",
+		Fix:     "run \"go mod init\" in the root of your project",
+	}
+}
+
+// checkBuildTag makes sure that the tools file is only built with the build tag that toolbox writes, and never in a normal build
+func checkBuildTag(p *parsedOptions) *Check {
+	check := &Check{Name: "tools file"}
+	tag := "tools"
+	if p.mode != SharedMode {
+		tag = "ignore"
+	}
+
+	file, err := os.Open(p.toolsfileName)
+	if os.IsNotExist(err) {
+		check.Status = CheckWarn
+		check.Message = fmt.Sprintf("%s does not exist", p.toolsfileName)
+		check.Fix = "add a tool with \"toolbox add\""
+		return check
+	} else if err != nil {
+		check.Status = CheckFail
+		check.Message = fmt.Sprintf("error opening %s: %v", p.toolsfileName, err)
+		return check
+	}
+	defer file.Close()
+
+	var expr constraint.Expr
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+		if !constraint.IsGoBuild(line) && !constraint.IsPlusBuild(line) {
+			continue
+		}
+		expr, err = constraint.Parse(line)
+		if err != nil {
+			check.Status = CheckFail
+			check.Message = fmt.Sprintf("invalid build constraint in %s: %v", p.toolsfileName, err)
+			check.Fix = fmt.Sprintf("replace it with \"//go:build %s\"", tag)
+			return check
+		}
+		if constraint.IsGoBuild(line) {
+			// go:build lines take precedence over +build lines
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		check.Status = CheckFail
+		check.Message = fmt.Sprintf("error reading %s: %v", p.toolsfileName, err)
+		return check
+	}
+
+	if expr == nil || expr.Eval(func(string) bool { return false }) || !expr.Eval(func(t string) bool { return t == tag }) {
+		check.Status = CheckFail
+		check.Message = fmt.Sprintf("%s is not limited to the \"%s\" build tag, so it may be built into your project", p.toolsfileName, tag)
+		check.Fix = fmt.Sprintf("add \"//go:build %s\" to the top of the file", tag)
+		return check
+	}
+	check.Status = CheckPass
+	check.Message = fmt.Sprintf("%s is limited to the \"%s\" build tag", p.toolsfileName, tag)
+	return check
+}
+
+// checkTracked makes sure that a version is recorded for every tool
+func checkTracked(tools []*tool, p *parsedOptions) []*Check {
+	parseFile, err := readTrackingModfile(p)
+	if errors.Is(err, os.ErrNotExist) && len(tools) == 0 {
+		return nil
+	} else if err != nil {
+		return []*Check{{
+			Name:    "versions",
+			Status:  CheckFail,
+			Message: err.Error(),
+			Fix:     "run \"go mod init\" in the root of your project",
+		}}
+	}
+
+	checks := []*Check{}
+	for _, t := range tools {
+		name := "version of " + t.id()
+		version := trackedVersion(t, parseFile)
+		if version == "" {
+			checks = append(checks, &Check{
+				Name:    name,
+				Status:  CheckFail,
+				Message: "no version is recorded",
+				Fix:     fmt.Sprintf("run \"toolbox add %s\"", t.id()),
+			})
+			continue
+		}
+		checks = append(checks, &Check{
+			Name:    name,
+			Status:  CheckPass,
+			Message: version,
+		})
+	}
+	return checks
+}
+
+func checkBinaries(tools []*tool, p *parsedOptions) []*Check {
+	checks := []*Check{}
+	owners := map[string]string{}
+	for _, t := range tools {
+		name := "binary for " + t.id()
+		binary := binaryPath(t, p)
+		if owner, ok := owners[binary]; ok {
+			checks = append(checks, &Check{
+				Name:    name,
+				Status:  CheckFail,
+				Message: fmt.Sprintf("%s is also the binary for %s", binary, owner),
+				Fix:     "re-add one of the tools with \"toolbox add --name\"",
+			})
+			continue
+		}
+		owners[binary] = t.id()
+
+		if _, err := os.Stat(binary); err != nil {
+			checks = append(checks, &Check{
+				Name:    name,
+				Status:  CheckWarn,
+				Message: fmt.Sprintf("%s is not installed", binary),
+				Fix:     "run \"toolbox sync\"",
+			})
+			continue
+		}
+		checks = append(checks, &Check{
+			Name:    name,
+			Status:  CheckPass,
+			Message: binary,
+		})
+	}
+	return checks
+}
+
+// checkGitignore makes sure that the tools directory is kept out of source control
+func checkGitignore(p *parsedOptions) *Check {
+	check := &Check{Name: "gitignore"}
+	if _, err := exec.LookPath("git"); err != nil {
+		check.Status = CheckPass
+		check.Message = "git not found, skipping"
+		return check
+	}
+
+	gitCheck := exec.Command("git", "check-ignore", "-q", filepath.Base(p.toolsdirName))
+	gitCheck.Dir = filepath.Dir(p.toolsdirName)
+	err := gitCheck.Run()
+	exitErr := &exec.ExitError{}
+	switch {
+	case err == nil:
+		check.Status = CheckPass
+		check.Message = fmt.Sprintf("%s is ignored by git", p.toolsdirName)
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		check.Status = CheckWarn
+		check.Message = fmt.Sprintf("%s is not ignored by git", p.toolsdirName)
+		check.Fix = fmt.Sprintf("add \"/%s/\" to your .gitignore", filepath.Base(p.toolsdirName))
+	default:
+		// Most likely not a git repository, in which case there's nothing to ignore
+		check.Status = CheckPass
+		check.Message = "not a git repository, skipping"
+	}
+	return check
+}
@@ -0,0 +1,251 @@
+package toolbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/kballard/go-shellquote"
+)
+
+// Shell is a shell that Activate can write an activation script for
+type Shell int
+
+const (
+	// PosixShell writes a script for any POSIX compatible shell, such as sh or dash
+	PosixShell Shell = iota
+	// BashShell writes a script for bash
+	BashShell
+	// ZshShell writes a script for zsh
+	ZshShell
+	// FishShell writes a script for fish
+	FishShell
+)
+
+var shellNames = map[Shell]string{
+	PosixShell: "posix",
+	BashShell:  "bash",
+	ZshShell:   "zsh",
+	FishShell:  "fish",
+}
+
+func (s Shell) String() string {
+	if name, ok := shellNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Shell(%d)", int(s))
+}
+
+// ParseShell converts the name of a shell, as returned by Shell.String, back into a Shell
+func ParseShell(name string) (Shell, error) {
+	for shell, shellName := range shellNames {
+		if shellName == name {
+			return shell, nil
+		}
+	}
+	return PosixShell, fmt.Errorf("unknown shell \"%s\"", name)
+}
+
+// deactivateFunction is the name of the shell function, defined by activation scripts, that undoes the activation
+const deactivateFunction = "toolbox_deactivate"
+
+// activeVar is set to the base directory while a project is activated, so that prompts and scripts can tell
+const activeVar = "TOOLBOX_ACTIVE"
+
+var validEnvName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// envVar is a change to an environment variable.  If prepend is set, value is a list entry to add to the front of the variable, rather than a replacement.
+type envVar struct {
+	name    string
+	value   string
+	prepend bool
+}
+
+// toolEnv returns the environment that vendored tools are run in, as changes to the current environment
+func toolEnv(tools []*tool, p *parsedOptions) ([]*envVar, error) {
+	absToolsdir, err := filepath.Abs(p.toolsdirName)
+	if err != nil {
+		return nil, fmt.Errorf("error finding absolute path to toolsdir %s: %w", p.toolsdirName, err)
+	}
+
+	// GOBIN is set so that tools that install other tools still prefer the tool directory
+	vars := []*envVar{
+		{name: "GOBIN", value: absToolsdir},
+		{name: "PATH", value: absToolsdir, prepend: true},
+	}
+
+	declared := map[string]string{}
+	declaredBy := map[string]string{}
+	for _, t := range tools {
+		for name, value := range t.Env {
+			if other, ok := declaredBy[name]; ok && declared[name] != value {
+				return nil, fmt.Errorf("tools %s and %s set %s to different values", other, t.id(), name)
+			}
+			declared[name] = value
+			declaredBy[name] = t.id()
+		}
+	}
+	names := make([]string, 0, len(declared))
+	for name := range declared {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		vars = append(vars, &envVar{name: name, value: declared[name]})
+	}
+	return vars, nil
+}
+
+// environ applies vars to the current environment, in the form used by exec.Cmd
+func environ(vars []*envVar) []string {
+	env := os.Environ()
+	for _, v := range vars {
+		value := v.value
+		if v.prepend {
+			value += string(filepath.ListSeparator) + os.Getenv(v.name)
+		}
+		env = append(env, v.name+"="+value)
+	}
+	return env
+}
+
+// activeEnv returns the environment of an activated project, which is the environment tools are run in, along with TOOLBOX_ACTIVE
+func activeEnv(p *parsedOptions) ([]*envVar, error) {
+	tools, err := readTools(p)
+	if err != nil {
+		return nil, err
+	}
+	vars, err := toolEnv(tools, p)
+	if err != nil {
+		return nil, err
+	}
+	absBasedir, err := filepath.Abs(p.basedirName)
+	if err != nil {
+		return nil, fmt.Errorf("error finding absolute path to base directory %s: %w", p.basedirName, err)
+	}
+	return append(vars, &envVar{name: activeVar, value: absBasedir}), nil
+}
+
+// Activate returns a script that, when evaluated by the given shell, sets up the same environment that Do runs tools in, so that vendored tools can be run directly.  The script also defines a "toolbox_deactivate" function, which restores the environment as it was.  Evaluating the script again, for example after tools are added, deactivates the previous environment first.
+func Activate(shell Shell, options ...Option) (string, error) {
+	p, err := parseOptions(options...)
+	if err != nil {
+		return "", fmt.Errorf("error parsing options: %w", err)
+	}
+
+	vars, err := activeEnv(p)
+	if err != nil {
+		return "", err
+	}
+
+	if shell == FishShell {
+		return fishActivate(vars), nil
+	}
+	return posixActivate(shell, vars), nil
+}
+
+// savedName is the variable that the value of name is saved in, while activated
+func savedName(name string) string {
+	return "_TOOLBOX_OLD_" + name
+}
+
+func posixActivate(shell Shell, vars []*envVar) string {
+	// Shells cache where commands were found, which would hide tools that are also on the regular PATH
+	rehash := ""
+	switch shell {
+	case BashShell:
+		rehash = "hash -r 2>/dev/null\n"
+	case ZshShell:
+		rehash = "rehash\n"
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "if command -v %s >/dev/null 2>&1; then %s; fi\n", deactivateFunction, deactivateFunction)
+	for _, v := range vars {
+		old := savedName(v.name)
+		fmt.Fprintf(b, "if [ \"${%s+set}\" = set ]; then %s=$%s; else unset %s; fi\n", v.name, old, v.name, old)
+		value := shellquote.Join(v.value)
+		if v.prepend {
+			// The rest of the list is left to the shell, rather than copied from when the script was written
+			value += fmt.Sprintf("\"%c$%s\"", filepath.ListSeparator, v.name)
+		}
+		fmt.Fprintf(b, "%s=%s\nexport %s\n", v.name, value, v.name)
+	}
+	b.WriteString(rehash)
+
+	fmt.Fprintf(b, "%s() {\n", deactivateFunction)
+	for _, v := range vars {
+		old := savedName(v.name)
+		fmt.Fprintf(b, "\tif [ \"${%s+set}\" = set ]; then %s=$%s; export %s; else unset %s; fi\n", old, v.name, old, v.name, v.name)
+		fmt.Fprintf(b, "\tunset %s\n", old)
+	}
+	if rehash != "" {
+		b.WriteString("\t" + rehash)
+	}
+	fmt.Fprintf(b, "\tunset -f %s\n}\n", deactivateFunction)
+	return b.String()
+}
+
+func fishActivate(vars []*envVar) string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "if functions -q %s; %s; end\n", deactivateFunction, deactivateFunction)
+	for _, v := range vars {
+		old := savedName(v.name)
+		fmt.Fprintf(b, "if set -q %s; set -gx %s $%s; else; set -e %s; end\n", v.name, old, v.name, old)
+		if v.prepend {
+			fmt.Fprintf(b, "set -gx %s %s $%s\n", v.name, fishQuote(v.value), v.name)
+			continue
+		}
+		fmt.Fprintf(b, "set -gx %s %s\n", v.name, fishQuote(v.value))
+	}
+
+	fmt.Fprintf(b, "function %s\n", deactivateFunction)
+	for _, v := range vars {
+		old := savedName(v.name)
+		fmt.Fprintf(b, "\tif set -q %s; set -gx %s $%s; else; set -e %s; end\n", old, v.name, old, v.name)
+		fmt.Fprintf(b, "\tset -e %s\n", old)
+	}
+	fmt.Fprintf(b, "\tfunctions -e %s\nend\n", deactivateFunction)
+	return b.String()
+}
+
+// fishQuote quotes s as a single argument to fish, which only treats backslashes and single quotes specially inside of single quotes
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
+
+// Subshell starts an interactive shell in the environment that Do runs tools in, and waits for it to exit.  The shell is taken from $SHELL, or %ComSpec% on Windows.
+func Subshell(options ...Option) error {
+	p, err := parseOptions(options...)
+	if err != nil {
+		return fmt.Errorf("error parsing options: %w", err)
+	}
+
+	vars, err := activeEnv(p)
+	if err != nil {
+		return err
+	}
+
+	shell := os.Getenv("SHELL")
+	if runtime.GOOS == "windows" {
+		shell = os.Getenv("ComSpec")
+	}
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+
+	cmd := exec.Command(shell)
+	cmd.Env = environ(vars)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	p.logger.Printf("starting %s with tools from %s", shell, p.toolsdirName)
+	return cmd.Run()
+}
@@ -0,0 +1,106 @@
+package toolbox
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the current output")
+
+// testActivateVars are the changes an activation script makes in these tests, with values that need quoting in every shell
+func testActivateVars() []*envVar {
+	toolsdir := "/work/my project/it's \"here\"/_tools"
+	return []*envVar{
+		{name: "GOBIN", value: toolsdir},
+		{name: "PATH", value: toolsdir, prepend: true},
+		{name: "TOOL_CONFIG", value: `$HOME\config 'a' "b" ` + "`c`"},
+		{name: activeVar, value: "/work/my project"},
+	}
+}
+
+func checkGolden(t *testing.T, name, actual string) {
+	filename := filepath.Join("testdata", "activate", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(actual), 0666); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("error reading golden file, run with -update to write it: %v", err)
+	}
+	if actual != string(expected) {
+		t.Errorf("script differs from %s, run with -update if the change is intended\ngot:\n%s\nexpected:\n%s", filename, actual, expected)
+	}
+}
+
+func TestActivateGolden(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("PATH is separated by semicolons on windows")
+	}
+	for _, shell := range []Shell{PosixShell, BashShell, ZshShell} {
+		t.Run(shell.String(), func(t *testing.T) {
+			checkGolden(t, shell.String(), posixActivate(shell, testActivateVars()))
+		})
+	}
+	t.Run(FishShell.String(), func(t *testing.T) {
+		checkGolden(t, FishShell.String(), fishActivate(testActivateVars()))
+	})
+}
+
+func TestActivateDeactivate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("activation scripts are for posix shells")
+	}
+
+	vars := testActivateVars()
+	// Each line is prefixed, so that empty values still show up
+	report := `printf 'GOBIN=%s\nPATH=%s\nTOOL_CONFIG=%s\nTOOLBOX_ACTIVE=%s\n' "${GOBIN-<unset>}" "$PATH" "${TOOL_CONFIG-<unset>}" "${TOOLBOX_ACTIVE-<unset>}"` + "\n"
+	for _, shell := range []Shell{PosixShell, BashShell} {
+		name := "sh"
+		if shell == BashShell {
+			name = "bash"
+		}
+		t.Run(shell.String(), func(t *testing.T) {
+			if _, err := exec.LookPath(name); err != nil {
+				t.Skipf("%s isn't installed", name)
+			}
+			script := posixActivate(shell, vars) + report +
+				// Activating twice must not lose what was there before the first activation
+				posixActivate(shell, vars) +
+				deactivateFunction + "\n" + report
+			cmd := exec.Command(name, "-c", script)
+			// GOBIN and TOOLBOX_ACTIVE are unset, and TOOL_CONFIG is set to something else, before activating
+			cmd.Env = []string{"PATH=/usr/bin:/bin", "TOOL_CONFIG=before"}
+			out, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("error running script: %v\n%s", err, out)
+			}
+
+			expected := strings.Join([]string{
+				"GOBIN=" + vars[0].value,
+				"PATH=" + vars[1].value + ":/usr/bin:/bin",
+				"TOOL_CONFIG=" + vars[2].value,
+				"TOOLBOX_ACTIVE=" + vars[3].value,
+				"GOBIN=<unset>",
+				"PATH=/usr/bin:/bin",
+				"TOOL_CONFIG=before",
+				"TOOLBOX_ACTIVE=<unset>",
+				"",
+			}, "\n")
+			if string(out) != expected {
+				t.Errorf("got\n%s\nexpected\n%s", out, expected)
+			}
+		})
+	}
+}
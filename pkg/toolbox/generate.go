@@ -0,0 +1,227 @@
+package toolbox
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kballard/go-shellquote"
+)
+
+// Generator is a command run by a //go:generate directive
+type Generator struct {
+	// File and Line give the location of the directive
+	File string `json:"file" yaml:"file"`
+	Line int    `json:"line" yaml:"line"`
+	// Command is the name of the binary that the directive runs
+	Command string `json:"command" yaml:"command"`
+	// Tracked reports whether Command is a tool or artifact managed by toolbox
+	Tracked bool `json:"tracked" yaml:"tracked"`
+}
+
+type listedPackage struct {
+	Dir          string
+	GoFiles      []string
+	CgoFiles     []string
+	TestGoFiles  []string
+	XTestGoFiles []string
+}
+
+// Generators finds the //go:generate directives in the given packages, which default to the package in the current directory, and reports which of the commands they run are tracked.  Commands that aren't binaries found on the PATH, such as "go", paths to scripts, and names defined with "-command", aren't included, and neither are commands allowed with AllowedCommandsOption.
+func Generators(packages []string, options ...Option) ([]*Generator, error) {
+	p, err := parseOptions(options...)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing options: %w", err)
+	}
+
+	tools, err := readTools(p)
+	if err != nil {
+		return nil, err
+	}
+	artifacts, err := readArtifacts(p)
+	if err != nil {
+		return nil, err
+	}
+	return generators(packages, tools, artifacts, p)
+}
+
+// generators finds the //go:generate directives in the given packages, and which of their commands are among the given tools and artifacts
+func generators(packages []string, tools []*tool, artifacts []*Artifact, p *parsedOptions) ([]*Generator, error) {
+	listed, err := goListPackages(packages, p)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := map[string]bool{"go": true}
+	for _, name := range p.allowedCommands {
+		allowed[name] = true
+	}
+
+	gens := []*Generator{}
+	for _, pkg := range listed {
+		files := []string{}
+		for _, list := range [][]string{pkg.GoFiles, pkg.CgoFiles, pkg.TestGoFiles, pkg.XTestGoFiles} {
+			for _, file := range list {
+				files = append(files, filepath.Join(pkg.Dir, file))
+			}
+		}
+
+		for _, file := range files {
+			directives, err := generateDirectives(file)
+			if err != nil {
+				return nil, err
+			}
+			// Names defined with -command are only visible in the file that defines them, the same as in go generate
+			aliases := map[string]bool{}
+			for _, d := range directives {
+				if d.alias != "" {
+					aliases[d.alias] = true
+				}
+			}
+			for _, d := range directives {
+				if aliases[d.command] || allowed[d.command] || strings.HasPrefix(d.command, "$") || strings.ContainsAny(d.command, `/\`) {
+					continue
+				}
+				_, _, tracked := resolveCommand(d.command, tools, artifacts, p)
+				gens = append(gens, &Generator{
+					File:    file,
+					Line:    d.line,
+					Command: d.command,
+					Tracked: tracked,
+				})
+			}
+		}
+	}
+	return gens, nil
+}
+
+// Generate runs "go generate" on the given packages, in the same environment that Do runs commands in, so that generators use the vendored tools.  With AutoSyncOption, tracked tools that the packages' directives run are installed first, if they're missing or out of date.  Returns the generators found in the packages, as Generators would, even if go generate fails, so that callers can report untracked tools without scanning the packages again.
+func Generate(packages []string, options ...Option) ([]*Generator, error) {
+	p, err := parseOptions(options...)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing options: %w", err)
+	}
+
+	tools, err := readTools(p)
+	if err != nil {
+		return nil, err
+	}
+	artifacts, err := readArtifacts(p)
+	if err != nil {
+		return nil, err
+	}
+	gens, err := generators(packages, tools, artifacts, p)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.autoSync {
+		stale := map[string]bool{}
+		for _, g := range gens {
+			id, _, ok := resolveCommand(g.Command, tools, artifacts, p)
+			if !ok || stale[id] {
+				continue
+			}
+			current, err := isInstalled(id, tools, artifacts, p)
+			if err != nil {
+				return nil, err
+			}
+			if !current {
+				p.logger.Printf("%s is missing or out of date, syncing", id)
+				stale[id] = true
+			}
+		}
+		if len(stale) > 0 {
+			if err := syncTools(p, stale); err != nil {
+				return gens, err
+			}
+		}
+	}
+
+	cmd, err := CommandOpts(p.goBinary, append([]string{"generate"}, packages...), options...)
+	if err != nil {
+		return gens, err
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	p.logger.Printf("calling \"%s\"", shellquote.Join(cmd.Args...))
+	if err := cmd.Run(); err != nil {
+		return gens, fmt.Errorf("error calling go generate: %w", err)
+	}
+	return gens, nil
+}
+
+// goListPackages lists the given packages from the current directory, as go generate would see them
+func goListPackages(packages []string, p *parsedOptions) ([]*listedPackage, error) {
+	// -e keeps packages with errors in the output, as go generate still runs the directives in them
+	golist := goCommand(p, append([]string{"list", "-e", "-json"}, packages...)...)
+	golist.Dir = ""
+	golist.Stderr = newLogWriter(p.logger)
+	p.logger.Printf("calling \"%s\"", shellquote.Join(golist.Args...))
+	out, err := golist.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error calling go list: %w", err)
+	}
+
+	listed := []*listedPackage{}
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	for {
+		pkg := &listedPackage{}
+		if err := decoder.Decode(pkg); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error parsing go list output: %w", err)
+		}
+		listed = append(listed, pkg)
+	}
+	return listed, nil
+}
+
+type generateDirective struct {
+	line    int
+	command string
+	// alias is the name defined by a "-command" directive, whose command is the one the alias runs
+	alias string
+}
+
+// generateDirectives reads the //go:generate directives in a file.  Like go generate, directives must start at the beginning of a line.
+func generateDirectives(file string) ([]*generateDirective, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", file, err)
+	}
+	defer f.Close()
+
+	directives := []*generateDirective{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if !strings.HasPrefix(text, "//go:generate ") && !strings.HasPrefix(text, "//go:generate\t") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(text, "//go:generate"))
+		d := &generateDirective{line: line}
+		if len(fields) > 0 && fields[0] == "-command" {
+			if len(fields) < 3 {
+				continue
+			}
+			d.alias = fields[1]
+			fields = fields[2:]
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		d.command = strings.Trim(fields[0], `"`)
+		directives = append(directives, d)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", file, err)
+	}
+	return directives, nil
+}
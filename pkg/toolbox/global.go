@@ -0,0 +1,21 @@
+package toolbox
+
+import (
+	"fmt"
+	"path"
+)
+
+// resolveModule finds the module providing pkg at the given version query, by trying each parent path of pkg in turn, longest first.  An empty query selects the latest version.
+func resolveModule(pkg, query string, p *parsedOptions) (*listedModule, error) {
+	if query == "" {
+		query = "latest"
+	}
+
+	for modulePath := pkg; modulePath != "." && modulePath != "/"; modulePath = path.Dir(modulePath) {
+		modules, err := goListModules([]string{"-versions", modulePath + "@" + query}, p)
+		if err == nil && len(modules) == 1 {
+			return modules[0], nil
+		}
+	}
+	return nil, fmt.Errorf("no module found providing package %s@%s", pkg, query)
+}
@@ -0,0 +1,93 @@
+package toolbox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// allowedDir is the directory inside of the tools directory where links to allowed host commands are kept, for isolated commands
+const allowedDir = ".allowed"
+
+// isolatedPath returns the PATH used by isolated commands, which only contains the tools directory, and a directory of links to the allowed host commands
+func isolatedPath(p *parsedOptions) (string, error) {
+	absToolsdir, err := filepath.Abs(p.toolsdirName)
+	if err != nil {
+		return "", fmt.Errorf("error finding absolute path to toolsdir %s: %w", p.toolsdirName, err)
+	}
+	if len(p.allowedCommands) == 0 {
+		return absToolsdir, nil
+	}
+	dir, err := linkAllowed(absToolsdir, p)
+	if err != nil {
+		return "", err
+	}
+	return absToolsdir + string(filepath.ListSeparator) + dir, nil
+}
+
+// linkAllowed finds every allowed command on the host PATH, and links them into a directory of their own.  The directory is named after the commands it links to, so it's only created again when they change.
+func linkAllowed(absToolsdir string, p *parsedOptions) (string, error) {
+	targets := make([]string, len(p.allowedCommands))
+	for i, name := range p.allowedCommands {
+		found, err := exec.LookPath(name)
+		if err != nil {
+			return "", fmt.Errorf("error finding allowed command %s: %w", name, err)
+		}
+		targets[i], err = filepath.Abs(found)
+		if err != nil {
+			return "", fmt.Errorf("error finding absolute path to %s: %w", found, err)
+		}
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(targets, "\n")))
+	parent := filepath.Join(absToolsdir, allowedDir)
+	dir := filepath.Join(parent, hex.EncodeToString(sum[:8]))
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+
+	// Links are made in a temporary directory that's moved into place, so that commands running at the same time never see a partial directory
+	if err := os.MkdirAll(parent, 0777); err != nil {
+		return "", fmt.Errorf("error creating directory %s: %w", parent, err)
+	}
+	tmp, err := ioutil.TempDir(parent, ".tmp")
+	if err != nil {
+		return "", fmt.Errorf("error creating temporary directory in %s: %w", parent, err)
+	}
+	defer os.RemoveAll(tmp)
+	for _, target := range targets {
+		p.logger.Printf("allowing %s", target)
+		if err := os.Symlink(target, filepath.Join(tmp, filepath.Base(target))); err != nil {
+			return "", fmt.Errorf("error linking allowed command %s: %w", target, err)
+		}
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		if _, statErr := os.Stat(dir); statErr != nil {
+			return "", fmt.Errorf("error moving allowed commands into %s: %w", dir, err)
+		}
+	}
+	return dir, nil
+}
+
+// lookIsolatedPath finds command in the given PATH, the same way that exec.LookPath searches the real one
+func lookIsolatedPath(command, path string) (string, error) {
+	names := []string{command}
+	if runtime.GOOS == "windows" && filepath.Ext(command) == "" {
+		names = []string{command + ".exe", command + ".cmd", command + ".bat"}
+	}
+	for _, dir := range filepath.SplitList(path) {
+		for _, name := range names {
+			candidate := filepath.Join(dir, name)
+			if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+				return candidate, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%s is neither a tracked tool nor an allowed command", command)
+}
@@ -1,6 +1,8 @@
 package toolbox
 
 import (
+	"debug/buildinfo"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -8,12 +10,41 @@ import (
 	"strings"
 
 	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
 )
 
 type Tool struct {
-	Package    string `json:"package"`
-	Version    string `json:"version"`
-	BuildFlags string `json:"build_flags"`
+	Package    string       `json:"package" yaml:"package"`
+	Alias      string       `json:"alias,omitempty" yaml:"alias,omitempty"`
+	Module     string       `json:"module" yaml:"module"`
+	Version    string       `json:"version" yaml:"version"`
+	BuildFlags string       `json:"build_flags" yaml:"build_flags"`
+	Indirect   bool         `json:"indirect" yaml:"indirect"`
+	Replace    *Replacement `json:"replace,omitempty" yaml:"replace,omitempty"`
+	// Env holds environment variables that are set whenever tools are run
+	Env map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+
+	// Binary is the file name of the tool's executable, and BinaryPath is its absolute path in the tools directory
+	Binary     string `json:"binary" yaml:"binary"`
+	BinaryPath string `json:"binary_path" yaml:"binary_path"`
+	// Installed reports whether the executable exists.  If it does, InstalledVersion is the version of the tool's module that it was built from, which differs from Version when the tool is stale.
+	Installed        bool   `json:"installed" yaml:"installed"`
+	InstalledVersion string `json:"installed_version,omitempty" yaml:"installed_version,omitempty"`
+	// NotPrepared reports that the module the tool is built from hasn't been generated or recorded yet, so Module is unknown.  Listing tools never generates it.
+	NotPrepared bool `json:"not_prepared,omitempty" yaml:"not_prepared,omitempty"`
+}
+
+// Replacement describes a replace directive that swaps out the module a tool is built from.  Path is either a module path, in which case Version is set, or a local directory.
+type Replacement struct {
+	Path    string `json:"path" yaml:"path"`
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+}
+
+// ToolModule is a group of tools that are all provided by the same module, and therefore share a version
+type ToolModule struct {
+	Path    string  `json:"path" yaml:"path"`
+	Version string  `json:"version" yaml:"version"`
+	Tools   []*Tool `json:"tools" yaml:"tools"`
 }
 
 func List(options ...Option) ([]*Tool, error) {
@@ -21,17 +52,118 @@ func List(options ...Option) ([]*Tool, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error parsing options: %w", err)
 	}
+	return list(p)
+}
+
+// ListModules lists all tools, grouped by the module that provides them
+func ListModules(options ...Option) ([]*ToolModule, error) {
+	p, err := parseOptions(options...)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing options: %w", err)
+	}
 
+	tools, err := list(p)
+	if err != nil {
+		return nil, err
+	}
+
+	modules := []*ToolModule{}
+	byPath := map[string]*ToolModule{}
+	for _, t := range tools {
+		m, ok := byPath[t.Module]
+		if !ok {
+			m = &ToolModule{
+				Path:    t.Module,
+				Version: t.Version,
+			}
+			byPath[t.Module] = m
+			modules = append(modules, m)
+		}
+		m.Tools = append(m.Tools, t)
+	}
+	return modules, nil
+}
+
+func list(p *parsedOptions) ([]*Tool, error) {
 	tools, err := readTools(p)
 	if err != nil {
 		return nil, err
 	}
 
-	filename := filepath.Join(p.basedirName, "go.mod")
+	parseFile, err := readTrackingModfile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	retVals := make([]*Tool, len(tools))
+	for i, t := range tools {
+		mod, err := toolModule(t, parseFile, p)
+		notPrepared := errors.Is(err, errNotPrepared)
+		if err != nil && !notPrepared {
+			return nil, err
+		}
+
+		retVals[i] = &Tool{
+			Package:     t.Pkg,
+			Alias:       t.Alias,
+			Module:      mod.Path,
+			Version:     mod.Version,
+			BuildFlags:  t.BuildFlags,
+			Env:         t.Env,
+			NotPrepared: notPrepared,
+		}
+		if notPrepared {
+			retVals[i].Version = t.Version
+		}
+		if err := setInstallInfo(retVals[i], t, p); err != nil {
+			return nil, err
+		}
+		if notPrepared {
+			continue
+		}
+
+		// Replacements and indirect requirements only exist in a go.mod, which global mode doesn't have
+		toolParseFile := parseFile
+		if isolated(t, p) {
+			dir, err := perToolModuleDir(t, p)
+			if err != nil {
+				return nil, err
+			}
+			toolParseFile, err = readModfileIn(dir)
+			if os.IsNotExist(errors.Unwrap(err)) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		if toolParseFile == nil {
+			continue
+		}
+		// Generated modules of isolated tools contain no code importing the tool, so the requirement is always indirect there
+		if req := findRequire(t.Pkg, toolParseFile); req != nil && !isolated(t, p) {
+			retVals[i].Indirect = req.Indirect
+		}
+		if rep := findReplace(mod, toolParseFile); rep != nil {
+			retVals[i].Replace = &Replacement{
+				Path:    rep.New.Path,
+				Version: rep.New.Version,
+			}
+		}
+	}
+	return retVals, nil
+}
+
+func readModfile(p *parsedOptions) (*modfile.File, error) {
+	return readModfileIn(p.moduleDir)
+}
+
+func readModfileIn(dir string) (*modfile.File, error) {
+	filename := filepath.Join(dir, "go.mod")
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, fmt.Errorf("error opening modfile %s: %w", filename, err)
 	}
+	defer file.Close()
 	bytes, err := ioutil.ReadAll(file)
 	if err != nil {
 		return nil, fmt.Errorf("error reading in modflie %s: %w", filename, err)
@@ -40,26 +172,134 @@ func List(options ...Option) ([]*Tool, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error parsing modfile %s: %w", filename, err)
 	}
+	return parseFile, nil
+}
 
-	retVals := make([]*Tool, len(tools))
-	for i, t := range tools {
-		var version string
-		for _, m := range parseFile.Require {
-			if strings.HasPrefix(t.Pkg, m.Mod.Path) {
-				version = m.Mod.Version
-				break
-			}
+// readTrackingModfile reads the go.mod file that tool versions are tracked in.  Outside of shared mode, versions are tracked in the toolsfile instead, and nil is returned.
+func readTrackingModfile(p *parsedOptions) (*modfile.File, error) {
+	if p.mode != SharedMode {
+		return nil, nil
+	}
+	return readModfile(p)
+}
+
+// trackedVersion returns the version that a tool is pinned to, from either the toolsfile or the tracking go.mod
+func trackedVersion(t *tool, parseFile *modfile.File) string {
+	if t.Version != "" || parseFile == nil {
+		return t.Version
+	}
+	return moduleVersion(t.Pkg, parseFile)
+}
+
+// errNotPrepared is returned by toolModule when the module a tool is built from hasn't been recorded or generated yet
+var errNotPrepared = errors.New("not prepared")
+
+// toolModule returns the module and version that a tool is built from.  Nothing is downloaded or generated, so if the module isn't known yet, an error wrapping errNotPrepared is returned.
+func toolModule(t *tool, parseFile *modfile.File, p *parsedOptions) (module.Version, error) {
+	if t.Module != "" && t.Version != "" {
+		return module.Version{Path: t.Module, Version: t.Version}, nil
+	}
+
+	switch {
+	case p.mode == GlobalMode:
+		return module.Version{}, fmt.Errorf("module of %s is %w, run \"toolbox add\" to record it", t.id(), errNotPrepared)
+	case isolated(t, p):
+		dir, err := perToolModuleDir(t, p)
+		if err != nil {
+			return module.Version{}, err
 		}
-		if version == "" {
-			return nil, fmt.Errorf("no version for package %s found", t.Pkg)
+		parseFile, err = readModfileIn(dir)
+		if os.IsNotExist(errors.Unwrap(err)) {
+			return module.Version{}, fmt.Errorf("module of %s is %w, run \"toolbox sync\" to generate it", t.id(), errNotPrepared)
+		} else if err != nil {
+			return module.Version{}, err
 		}
+	}
 
-		retVals[i] = &Tool{
-			Package:    t.Pkg,
-			Version:    version,
-			BuildFlags: t.BuildFlags,
+	req := findRequire(t.Pkg, parseFile)
+	if req == nil {
+		return module.Version{}, fmt.Errorf("no version for package %s found", t.Pkg)
+	}
+	return req.Mod, nil
+}
+
+// findRequire returns the requirement for the module providing pkg, or nil if no module is found.  If several modules could provide pkg, the one with the longest path wins, the same way go resolves nested modules.
+func findRequire(pkg string, parseFile *modfile.File) *modfile.Require {
+	var found *modfile.Require
+	for _, m := range parseFile.Require {
+		if !inModule(pkg, m.Mod.Path) {
+			continue
+		}
+		if found == nil || len(m.Mod.Path) > len(found.Mod.Path) {
+			found = m
+		}
+	}
+	return found
+}
+
+// setInstallInfo fills in where a tool's executable should be, and what version of the tool it actually contains
+func setInstallInfo(t *Tool, tracked *tool, p *parsedOptions) error {
+	binary := binaryPath(tracked, p)
+	absBinary, err := filepath.Abs(binary)
+	if err != nil {
+		return fmt.Errorf("error finding absolute path to %s: %w", binary, err)
+	}
+	t.Binary = filepath.Base(binary)
+	t.BinaryPath = absBinary
+
+	if _, err := os.Stat(absBinary); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error checking for binary %s: %w", absBinary, err)
+	}
+	t.Installed = true
+
+	info, err := buildinfo.ReadFile(absBinary)
+	if err != nil {
+		// Not a go binary, or built without module information, so there's no version to report
+		p.logger.Printf("unable to read build info from %s: %v", absBinary, err)
+		return nil
+	}
+	if info.Main.Path == t.Module {
+		t.InstalledVersion = info.Main.Version
+		return nil
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == t.Module {
+			t.InstalledVersion = dep.Version
+			return nil
 		}
+	}
+	return nil
+}
+
+// inModule reports whether pkg lies within the module at modulePath
+func inModule(pkg, modulePath string) bool {
+	return pkg == modulePath || strings.HasPrefix(pkg, modulePath+"/")
+}
 
+// findReplace returns the replace directive that applies to mod, or nil if the module isn't replaced.  A replacement of a specific version takes precedence over a replacement of all versions, as it does in go.
+func findReplace(mod module.Version, parseFile *modfile.File) *modfile.Replace {
+	var found *modfile.Replace
+	for _, r := range parseFile.Replace {
+		if r.Old.Path != mod.Path {
+			continue
+		}
+		if r.Old.Version == mod.Version {
+			return r
+		}
+		if r.Old.Version == "" {
+			found = r
+		}
 	}
-	return retVals, nil
+	return found
+}
+
+// moduleVersion returns the version of the module providing pkg, or the empty string if no module is found
+func moduleVersion(pkg string, parseFile *modfile.File) string {
+	req := findRequire(pkg, parseFile)
+	if req == nil {
+		return ""
+	}
+	return req.Mod.Version
 }
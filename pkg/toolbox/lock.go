@@ -0,0 +1,298 @@
+package toolbox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// LockedTool is a single entry in the lockfile, recording exactly what was installed for a tool.  Package is followed by "@alias" for side-by-side versions.
+type LockedTool struct {
+	Package string `json:"package"`
+	Module  string `json:"module"`
+	Version string `json:"version"`
+	Sum     string `json:"sum"`
+	// Replace is the replacement the module was built from, if any, in which case Sum is the hash of the replacement.  For a local directory, Sum is a hash of its contents.
+	Replace    string `json:"replace,omitempty"`
+	BuildFlags string `json:"build_flags,omitempty"`
+	GoVersion  string `json:"go_version"`
+	GOOS       string `json:"goos"`
+	GOARCH     string `json:"goarch"`
+	SHA256     string `json:"sha256"`
+}
+
+type lockfile struct {
+	Tools []*LockedTool `json:"tools"`
+}
+
+// VerifyStatus describes how an installed tool compares to its lockfile entry
+type VerifyStatus string
+
+const (
+	// VerifyOK means the tool binary matches the lockfile
+	VerifyOK VerifyStatus = "ok"
+	// VerifyMissing means the tool binary could not be found
+	VerifyMissing VerifyStatus = "missing"
+	// VerifyModified means the tool binary's hash differs from the lockfile
+	VerifyModified VerifyStatus = "modified"
+	// VerifyStale means the tool's tracked version or build flags differ from the lockfile
+	VerifyStale VerifyStatus = "stale"
+	// VerifyUnlocked means the tool has no entry in the lockfile
+	VerifyUnlocked VerifyStatus = "unlocked"
+)
+
+// VerifyResult is the result of verifying a single tool against the lockfile
+type VerifyResult struct {
+	Package  string       `json:"package"`
+	Status   VerifyStatus `json:"status"`
+	Expected string       `json:"expected,omitempty"`
+	Actual   string       `json:"actual,omitempty"`
+}
+
+// Verify re-hashes every tool binary, and compares it to the hashes stored in the lockfile
+func Verify(options ...Option) ([]*VerifyResult, error) {
+	p, err := parseOptions(options...)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing options: %w", err)
+	}
+
+	tools, err := readTools(p)
+	if err != nil {
+		return nil, err
+	}
+	parseFile, err := readTrackingModfile(p)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := readLockfile(p)
+	if err != nil {
+		return nil, err
+	}
+	locked := map[string]*LockedTool{}
+	for _, l := range lock.Tools {
+		locked[l.Package] = l
+	}
+
+	results := make([]*VerifyResult, len(tools))
+	for i, t := range tools {
+		result := &VerifyResult{Package: t.id()}
+		results[i] = result
+
+		l, ok := locked[t.id()]
+		if !ok {
+			result.Status = VerifyUnlocked
+			continue
+		}
+		if version := trackedVersion(t, parseFile); version != l.Version {
+			result.Status = VerifyStale
+			result.Expected = version
+			result.Actual = l.Version
+			continue
+		}
+		if t.BuildFlags != l.BuildFlags {
+			result.Status = VerifyStale
+			result.Expected = t.BuildFlags
+			result.Actual = l.BuildFlags
+			continue
+		}
+
+		hash, err := hashFile(binaryPath(t, p))
+		if os.IsNotExist(err) {
+			result.Status = VerifyMissing
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		result.Expected = l.SHA256
+		result.Actual = hash
+		if hash != l.SHA256 {
+			result.Status = VerifyModified
+			continue
+		}
+		result.Status = VerifyOK
+	}
+	return results, nil
+}
+
+func readLockfile(p *parsedOptions) (*lockfile, error) {
+	bytes, err := ioutil.ReadFile(p.lockfileName)
+	if os.IsNotExist(err) {
+		return &lockfile{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading lockfile %s: %w", p.lockfileName, err)
+	}
+	lock := &lockfile{}
+	if err := json.Unmarshal(bytes, lock); err != nil {
+		return nil, fmt.Errorf("error parsing lockfile %s: %w", p.lockfileName, err)
+	}
+	return lock, nil
+}
+
+// writeLockfile rewrites the lockfile for the given tools.  Entries are only recalculated for tools that were just installed, or that have no entry yet, so that drift in other binaries is still caught by Verify.  Tools without a binary are left out.
+func writeLockfile(tools []*tool, installed map[string]bool, p *parsedOptions) error {
+	existing, err := readLockfile(p)
+	if err != nil {
+		return err
+	}
+	locked := map[string]*LockedTool{}
+	for _, l := range existing.Tools {
+		locked[l.Package] = l
+	}
+
+	parseFile, err := readTrackingModfile(p)
+	if err != nil {
+		return err
+	}
+	goVer, err := goVersion(p)
+	if err != nil {
+		return err
+	}
+	goEnv, err := exec.Command(p.goBinary, "env", "GOOS", "GOARCH").Output()
+	if err != nil {
+		return fmt.Errorf("error finding target platform: %w", err)
+	}
+	platform := strings.Fields(string(goEnv))
+	if len(platform) != 2 {
+		return fmt.Errorf("unexpected output from go env: %s", string(goEnv))
+	}
+
+	lock := &lockfile{Tools: []*LockedTool{}}
+	for _, t := range tools {
+		if l, ok := locked[t.id()]; ok && !installed[t.id()] {
+			lock.Tools = append(lock.Tools, l)
+			continue
+		}
+
+		hash, err := hashFile(binaryPath(t, p))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		mod, err := toolModule(t, parseFile, p)
+		if err != nil {
+			return err
+		}
+		sum, replace, err := moduleSum(t, mod, p)
+		if err != nil {
+			return err
+		}
+
+		lock.Tools = append(lock.Tools, &LockedTool{
+			Package:    t.id(),
+			Module:     mod.Path,
+			Version:    mod.Version,
+			Sum:        sum,
+			Replace:    replace,
+			BuildFlags: t.BuildFlags,
+			GoVersion:  goVer,
+			GOOS:       platform[0],
+			GOARCH:     platform[1],
+			SHA256:     hash,
+		})
+	}
+
+	bytes, err := json.MarshalIndent(lock, "", "\t")
+	if err != nil {
+		return fmt.Errorf("error marshalling lockfile: %w", err)
+	}
+	p.logger.Printf("writing lockfile %s", p.lockfileName)
+	if err := ioutil.WriteFile(p.lockfileName, append(bytes, '\n'), 0666); err != nil {
+		return fmt.Errorf("error writing lockfile %s: %w", p.lockfileName, err)
+	}
+	return nil
+}
+
+// moduleSum finds the hash of the module a tool was built from, in the go.sum of the module it was built in, so that nothing has to be downloaded.  If the module is replaced, the hash of the replacement is returned instead, along with the replacement.
+func moduleSum(t *tool, mod module.Version, p *parsedOptions) (string, string, error) {
+	if p.mode == GlobalMode {
+		sum, err := cachedSum(mod, p)
+		return sum, "", err
+	}
+
+	dir, err := toolModuleDir(t, p)
+	if err != nil {
+		return "", "", err
+	}
+	parseFile, err := readModfileIn(dir)
+	if err != nil {
+		return "", "", err
+	}
+	replace := ""
+	if rep := findReplace(mod, parseFile); rep != nil {
+		if rep.New.Version == "" {
+			local := rep.New.Path
+			if !filepath.IsAbs(local) {
+				local = filepath.Join(dir, local)
+			}
+			sum, err := hashDir(local)
+			return sum, rep.New.Path, err
+		}
+		mod = rep.New
+		replace = rep.New.String()
+	}
+	sum, err := goSum(dir, mod)
+	return sum, replace, err
+}
+
+// goSum finds the hash of a module's contents in the go.sum file in dir
+func goSum(dir string, mod module.Version) (string, error) {
+	filename := filepath.Join(dir, "go.sum")
+	bytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("error reading go.sum file %s: %w", filename, err)
+	}
+	for _, line := range strings.Split(string(bytes), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[0] == mod.Path && fields[1] == mod.Version {
+			return fields[2], nil
+		}
+	}
+	return "", fmt.Errorf("no go.sum entry for %s found in %s", mod, filename)
+}
+
+// cachedSum finds the hash of a module's contents in the module cache, which is where go install records it when building outside of a module
+func cachedSum(mod module.Version, p *parsedOptions) (string, error) {
+	out, err := exec.Command(p.goBinary, "env", "GOMODCACHE").Output()
+	if err != nil {
+		return "", fmt.Errorf("error finding module cache: %w", err)
+	}
+	escapedPath, err := module.EscapePath(mod.Path)
+	if err != nil {
+		return "", fmt.Errorf("error escaping module path %s: %w", mod.Path, err)
+	}
+	escapedVersion, err := module.EscapeVersion(mod.Version)
+	if err != nil {
+		return "", fmt.Errorf("error escaping module version %s: %w", mod.Version, err)
+	}
+	filename := filepath.Join(strings.TrimSpace(string(out)), "cache", "download", filepath.FromSlash(escapedPath), "@v", escapedVersion+".ziphash")
+	bytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("error reading hash of %s from the module cache: %w", mod, err)
+	}
+	return strings.TrimSpace(string(bytes)), nil
+}
+
+func hashFile(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("error hashing %s: %w", filename, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,137 @@
+package toolbox
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/module"
+)
+
+const (
+	helloSum = "h1:hellohellohellohellohellohellohellohello="
+	forkSum  = "h1:forkforkforkforkforkforkforkforkforkfork="
+)
+
+// writeTestModule writes files, keyed by their slash separated path, into dir
+func writeTestModule(t *testing.T, dir string, files map[string]string) {
+	for name, contents := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(contents), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestModuleSum(t *testing.T) {
+	gosum := strings.Join([]string{
+		"example.com/hello v1.0.0 " + helloSum,
+		"example.com/hello v1.0.0/go.mod h1:hellomodhellomodhellomodhellomodhello=",
+		"example.com/fork v1.1.0 " + forkSum,
+		"example.com/fork v1.1.0/go.mod h1:forkmodforkmodforkmodforkmodforkmodfork=",
+		"",
+	}, "\n")
+	mod := module.Version{Path: "example.com/hello", Version: "v1.0.0"}
+
+	tests := []struct {
+		name    string
+		replace string
+		sum     string
+		local   bool
+	}{
+		{
+			name: "module",
+			sum:  helloSum,
+		},
+		{
+			name:    "replaced by module",
+			replace: "example.com/fork v1.1.0",
+			sum:     forkSum,
+		},
+		{
+			name:    "replaced by directory",
+			replace: "./fork",
+			local:   true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			gomod := "module example.com/project\n\nrequire example.com/hello v1.0.0\n"
+			if test.replace != "" {
+				gomod += "\nreplace example.com/hello => " + test.replace + "\n"
+			}
+			writeTestModule(t, dir, map[string]string{
+				"go.mod":          gomod,
+				"go.sum":          gosum,
+				"fork/go.mod":     "module example.com/hello\n",
+				"fork/hello.go":   "package main\n\nfunc main() {}\n",
+				"fork/.git/index": "not part of the module",
+			})
+			p, err := parseOptions(BasedirOption(dir))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := test.sum
+			if test.local {
+				expected, err = hashDir(filepath.Join(dir, "fork"))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			sum, replace, err := moduleSum(&tool{Pkg: "example.com/hello"}, mod, p)
+			if err != nil {
+				t.Fatalf("error finding module sum: %v", err)
+			}
+			if sum != expected {
+				t.Errorf("got sum %s, expected %s", sum, expected)
+			}
+			if replace != strings.Replace(test.replace, " ", "@", 1) {
+				t.Errorf("got replacement %q, expected %q", replace, test.replace)
+			}
+		})
+	}
+}
+
+func TestModuleSumMissing(t *testing.T) {
+	dir := t.TempDir()
+	writeTestModule(t, dir, map[string]string{
+		"go.mod": "module example.com/project\n\nrequire example.com/hello v1.0.0\n",
+		"go.sum": "example.com/hello v1.0.0/go.mod h1:hellomodhellomodhellomodhellomodhello=\n",
+	})
+	p, err := parseOptions(BasedirOption(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = moduleSum(&tool{Pkg: "example.com/hello"}, module.Version{Path: "example.com/hello", Version: "v1.0.0"}, p)
+	if err == nil || !strings.Contains(err.Error(), "no go.sum entry") {
+		t.Fatalf("expected a missing go.sum entry, got %v", err)
+	}
+}
+
+func TestModuleSumGlobal(t *testing.T) {
+	modcache := t.TempDir()
+	t.Setenv("GOMODCACHE", modcache)
+	// Upper case letters are escaped in the module cache
+	writeTestModule(t, modcache, map[string]string{
+		"cache/download/example.com/!hello/@v/v1.0.0.ziphash": helloSum + "\n",
+	})
+
+	p, err := parseOptions(BasedirOption(t.TempDir()), ModeOption(GlobalMode))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, replace, err := moduleSum(&tool{Pkg: "example.com/Hello"}, module.Version{Path: "example.com/Hello", Version: "v1.0.0"}, p)
+	if err != nil {
+		t.Fatalf("error finding module sum: %v", err)
+	}
+	if sum != helloSum || replace != "" {
+		t.Errorf("got sum %s and replacement %q, expected %s and no replacement", sum, replace, helloSum)
+	}
+}
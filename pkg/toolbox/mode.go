@@ -0,0 +1,40 @@
+package toolbox
+
+import (
+	"fmt"
+)
+
+// Mode determines how toolbox tracks tool versions, and where tools are built from
+type Mode int
+
+const (
+	// SharedMode tracks all tools in a single go.mod, either the project's own or the one set with ToolsmoduleOption.  This is the default.
+	SharedMode Mode = iota
+	// PerToolMode tracks tool versions in the toolsfile, and builds each tool from its own generated module in the tools directory.  Upgrading one tool never changes the dependencies of another.
+	PerToolMode
+	// GlobalMode tracks tool versions in the toolsfile, and installs each tool with "go install pkg@version", the same way a tool would be installed globally.  No go.mod is ever touched.
+	GlobalMode
+)
+
+var modeNames = map[Mode]string{
+	SharedMode:  "shared",
+	PerToolMode: "per_tool",
+	GlobalMode:  "global",
+}
+
+func (m Mode) String() string {
+	if name, ok := modeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// ParseMode converts the name of a mode, as returned by Mode.String, back into a Mode
+func ParseMode(name string) (Mode, error) {
+	for mode, modeName := range modeNames {
+		if modeName == name {
+			return mode, nil
+		}
+	}
+	return SharedMode, fmt.Errorf("unknown mode \"%s\"", name)
+}
@@ -0,0 +1,79 @@
+package toolbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/kballard/go-shellquote"
+	"golang.org/x/mod/module"
+)
+
+// toolsModulePath is the module path given to a separate tools module when toolbox creates it.  The module is never imported, so the name only needs to be valid.
+const toolsModulePath = "tools"
+
+// goCommand creates a go command that runs against the module where tools are tracked
+func goCommand(p *parsedOptions, args ...string) *exec.Cmd {
+	cmd := exec.Command(p.goBinary, args...)
+	cmd.Dir = p.moduleDir
+	return cmd
+}
+
+// buildFlagArgs splits stored build flags into arguments for the go command
+func buildFlagArgs(buildFlags string) ([]string, error) {
+	if buildFlags == "" {
+		return nil, nil
+	}
+	split, err := shellquote.Split(buildFlags)
+	if err != nil {
+		return nil, fmt.Errorf("error splitting args: %w", err)
+	}
+	return split, nil
+}
+
+type downloadedModule struct {
+	Path    string `json:"Path"`
+	Version string `json:"Version"`
+	Dir     string `json:"Dir"`
+	Sum     string `json:"Sum"`
+}
+
+// downloadModule makes sure a module is in the module cache, and returns where it was put
+func downloadModule(mod module.Version, p *parsedOptions) (*downloadedModule, error) {
+	download := goCommand(p, "mod", "download", "-json", mod.String())
+	download.Stderr = newLogWriter(p.logger)
+	p.logger.Printf("calling \"%s\"", shellquote.Join(download.Args...))
+	out, err := download.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error calling go mod download for %s: %w", mod, err)
+	}
+	downloaded := &downloadedModule{}
+	if err := json.Unmarshal(out, downloaded); err != nil {
+		return nil, fmt.Errorf("error parsing go mod download output: %w", err)
+	}
+	return downloaded, nil
+}
+
+// ensureModule initializes the separate tools module, if one is in use and doesn't exist yet
+func ensureModule(p *parsedOptions) error {
+	if p.toolsmoduleName == "" {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(p.moduleDir, "go.mod")); err == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(p.moduleDir, 0777); err != nil {
+		return fmt.Errorf("error creating tools module directory %s: %w", p.moduleDir, err)
+	}
+	gomod := goCommand(p, "mod", "init", toolsModulePath)
+	gomod.Stdout = newLogWriter(p.logger)
+	gomod.Stderr = newLogWriter(p.logger)
+	p.logger.Printf("calling \"%s\" in %s", shellquote.Join(gomod.Args...), p.moduleDir)
+	if err := gomod.Run(); err != nil {
+		return fmt.Errorf("error calling go mod init: %w", err)
+	}
+	return nil
+}
@@ -1,32 +1,64 @@
 package toolbox
 
 import (
+	"bytes"
 	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
 )
 
 // The default option values
 const (
 	defaultGo        = "go"
 	defaultGoimports = "goimports"
+	defaultJobs      = 1
+	defaultShim      = "toolbox"
 )
 
 func defaultBasedir(goCommand string) (string, error) {
+	gomod, err := goModPath(goCommand)
+	if err != nil {
+		return "", err
+	}
+	if gomod == "" {
+		return "", fmt.Errorf("no go module found, please initialize with \"go mod init\"")
+	}
+	return filepath.Dir(gomod), nil
+}
+
+// goModPath returns the path of the current go.mod, as reported by "go env GOMOD", or the empty string if there is none
+func goModPath(goCommand string) (string, error) {
 	out, err := exec.Command(goCommand, "env", "GOMOD").Output()
 	if err != nil {
 		return "", fmt.Errorf("error finding module root: %w", err)
 	}
-	if string(out) == "" {
-		return "", fmt.Errorf("no go module found, please initialize with \"go mod init\"")
+	gomod := strings.TrimSpace(string(out))
+	if gomod == os.DevNull {
+		// Reported when modules are enabled, but there is no go.mod
+		return "", nil
 	}
-	return filepath.Dir(string(out)), nil
+	return gomod, nil
 }
 
 func defaultToolsfile(basedir string) string {
 	return filepath.Join(basedir, "tools.go")
 }
 
+func defaultToolsmoduleToolsfile(moduleDir string) string {
+	return filepath.Join(moduleDir, "tools.go")
+}
+
+func defaultLockfile(basedir string) string {
+	return filepath.Join(basedir, "toolbox.lock")
+}
+
+func defaultArtifactsfile(basedir string) string {
+	return filepath.Join(basedir, "artifacts.json")
+}
+
 func defaultToolsdir(basedir string) string {
 	return filepath.Join(basedir, "_tools")
 }
@@ -40,8 +72,28 @@ type parsedOptions struct {
 	goimportsBinary string
 	toolsfileName   string
 	toolsdirName    string
+	lockfileName    string
+	artifactsName   string
 	basedirName     string
+	mode            Mode
+	backend         Backend
+	toolsmoduleName string
+	moduleDir       string
 	buildFlags      string
+	jobs            int
+	force           bool
+	replace         string
+	dropReplace     bool
+	name            string
+	env             map[string]string
+	trustDownload   bool
+	autoSync        bool
+	strict          bool
+	isolated        bool
+	allowedCommands []string
+	tasks           []*Task
+	shimCommand     string
+	shimConfigfile  string
 	logger          Logger
 }
 
@@ -106,6 +158,34 @@ func ToolsdirOption(toolsdirName string) Option {
 	return &toolsdirOption{toolsdirName: toolsdirName}
 }
 
+type lockfileOption struct {
+	lockfileName string
+}
+
+func (o *lockfileOption) apply(p *parsedOptions) *parsedOptions {
+	p.lockfileName = o.lockfileName
+	return p
+}
+
+// LockfileOption changes the default name/path of the lockfile, which records checksums of installed tools
+func LockfileOption(lockfileName string) Option {
+	return &lockfileOption{lockfileName: lockfileName}
+}
+
+type artifactsfileOption struct {
+	artifactsName string
+}
+
+func (o *artifactsfileOption) apply(p *parsedOptions) *parsedOptions {
+	p.artifactsName = o.artifactsName
+	return p
+}
+
+// ArtifactsfileOption changes the default name/path of the artifacts file, which lists tools that are downloaded as prebuilt binaries instead of built with go
+func ArtifactsfileOption(artifactsName string) Option {
+	return &artifactsfileOption{artifactsName: artifactsName}
+}
+
 type basedirOption struct {
 	basedirName string
 }
@@ -120,6 +200,48 @@ func BasedirOption(basedirName string) Option {
 	return &basedirOption{basedirName: basedirName}
 }
 
+type modeOption struct {
+	mode Mode
+}
+
+func (o *modeOption) apply(p *parsedOptions) *parsedOptions {
+	p.mode = o.mode
+	return p
+}
+
+// ModeOption changes how tool versions are tracked and built.  The default is SharedMode.
+func ModeOption(mode Mode) Option {
+	return &modeOption{mode: mode}
+}
+
+type backendOption struct {
+	backend Backend
+}
+
+func (o *backendOption) apply(p *parsedOptions) *parsedOptions {
+	p.backend = o.backend
+	return p
+}
+
+// BackendOption changes where the list of tools is stored.  The default is ToolsfileBackend.
+func BackendOption(backend Backend) Option {
+	return &backendOption{backend: backend}
+}
+
+type toolsmoduleOption struct {
+	toolsmoduleName string
+}
+
+func (o *toolsmoduleOption) apply(p *parsedOptions) *parsedOptions {
+	p.toolsmoduleName = o.toolsmoduleName
+	return p
+}
+
+// ToolsmoduleOption tracks tools in a separate go module in the given directory, instead of the project's module.  This keeps tool dependencies out of the project's go.mod.  A relative directory is relative to the base directory.
+func ToolsmoduleOption(toolsmoduleName string) Option {
+	return &toolsmoduleOption{toolsmoduleName: toolsmoduleName}
+}
+
 type buildFlagsOption struct {
 	buildFlags string
 }
@@ -134,6 +256,76 @@ func BuildFlagsOption(buildFlags string) Option {
 	return &buildFlagsOption{buildFlags: buildFlags}
 }
 
+type jobsOption struct {
+	jobs int
+}
+
+func (o *jobsOption) apply(p *parsedOptions) *parsedOptions {
+	p.jobs = o.jobs
+	return p
+}
+
+// JobsOption sets the maximum number of tools that are installed in parallel during a sync
+func JobsOption(jobs int) Option {
+	return &jobsOption{jobs: jobs}
+}
+
+type forceOption struct {
+	force bool
+}
+
+func (o *forceOption) apply(p *parsedOptions) *parsedOptions {
+	p.force = o.force
+	return p
+}
+
+// ForceOption causes sync to rebuild every tool, even if it appears to be up to date
+func ForceOption(force bool) Option {
+	return &forceOption{force: force}
+}
+
+type replaceOption struct {
+	replace string
+}
+
+func (o *replaceOption) apply(p *parsedOptions) *parsedOptions {
+	p.replace = o.replace
+	return p
+}
+
+// ReplaceOption causes add to build the tool from a replacement module, by writing a replace directive for the tool's module.  The replacement is either a local directory (relative paths are relative to the base directory), or a module path and version in the form "module@version".  Replacements are not supported in global mode.
+func ReplaceOption(replace string) Option {
+	return &replaceOption{replace: replace}
+}
+
+type dropReplaceOption struct {
+	dropReplace bool
+}
+
+func (o *dropReplaceOption) apply(p *parsedOptions) *parsedOptions {
+	p.dropReplace = o.dropReplace
+	return p
+}
+
+// DropReplaceOption causes add to remove any replacement of the tool's module, so that the tool is built from the module itself again.  It can't be combined with ReplaceOption.
+func DropReplaceOption(dropReplace bool) Option {
+	return &dropReplaceOption{dropReplace: dropReplace}
+}
+
+type nameOption struct {
+	name string
+}
+
+func (o *nameOption) apply(p *parsedOptions) *parsedOptions {
+	p.name = o.name
+	return p
+}
+
+// NameOption causes add to install the tool's binary under the given name, instead of the name go install would give it.  The name is remembered for future syncs.
+func NameOption(name string) Option {
+	return &nameOption{name: name}
+}
+
 type Logger interface {
 	Printf(string, ...interface{})
 }
@@ -153,6 +345,164 @@ func newLogWriter(logger Logger) *logWriter {
 	}
 }
 
+// bufferedLogger holds on to log output until it is flushed to another logger in one piece.  It may be written to from several goroutines at once, such as those copying the stdout and stderr of a command.
+type bufferedLogger struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (l *bufferedLogger) Printf(format string, v ...interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	fmt.Fprintf(&l.buf, format, v...)
+	if !bytes.HasSuffix(l.buf.Bytes(), []byte("\n")) {
+		l.buf.WriteByte('\n')
+	}
+}
+
+func (l *bufferedLogger) flush(logger Logger) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.buf.Len() == 0 {
+		return
+	}
+	logger.Printf("%s", strings.TrimSuffix(l.buf.String(), "\n"))
+	l.buf.Reset()
+}
+
+type envOption struct {
+	env map[string]string
+}
+
+func (o *envOption) apply(p *parsedOptions) *parsedOptions {
+	p.env = o.env
+	return p
+}
+
+// EnvOption causes add to record environment variables that the tool needs.  They're set whenever tools are run with Do or Command, and exported by Activate.
+func EnvOption(env map[string]string) Option {
+	return &envOption{env: env}
+}
+
+type trustDownloadOption struct {
+	trustDownload bool
+}
+
+func (o *trustDownloadOption) apply(p *parsedOptions) *parsedOptions {
+	p.trustDownload = o.trustDownload
+	return p
+}
+
+// TrustDownloadOption causes AddArtifact to accept a download that there's no expected hash for, and record its hash, so that only later downloads are checked.  Without it, an artifact must have a hash for the current platform or a checksums file.
+func TrustDownloadOption(trustDownload bool) Option {
+	return &trustDownloadOption{trustDownload: trustDownload}
+}
+
+type autoSyncOption struct {
+	autoSync bool
+}
+
+func (o *autoSyncOption) apply(p *parsedOptions) *parsedOptions {
+	p.autoSync = o.autoSync
+	return p
+}
+
+// AutoSyncOption causes Do and Command to install a tracked tool before running it, if its binary is missing or was built from a different version than is tracked
+func AutoSyncOption(autoSync bool) Option {
+	return &autoSyncOption{autoSync: autoSync}
+}
+
+// SyncMissingOption causes Do and Command to install a tracked tool before running it, if it's missing or out of date.
+//
+// Deprecated: use AutoSyncOption, which does the same thing.
+func SyncMissingOption(syncMissing bool) Option {
+	return AutoSyncOption(syncMissing)
+}
+
+type strictOption struct {
+	strict bool
+}
+
+func (o *strictOption) apply(p *parsedOptions) *parsedOptions {
+	p.strict = o.strict
+	return p
+}
+
+// StrictOption causes Do and Command to fail when running a tracked tool that isn't installed, rather than falling back to whatever version is on the PATH
+func StrictOption(strict bool) Option {
+	return &strictOption{strict: strict}
+}
+
+type shimCommandOption struct {
+	shimCommand string
+}
+
+func (o *shimCommandOption) apply(p *parsedOptions) *parsedOptions {
+	p.shimCommand = o.shimCommand
+	return p
+}
+
+// ShimCommandOption changes the toolbox executable that shims call.  The default is "toolbox", found on the PATH.
+func ShimCommandOption(shimCommand string) Option {
+	return &shimCommandOption{shimCommand: shimCommand}
+}
+
+type shimConfigfileOption struct {
+	shimConfigfile string
+}
+
+func (o *shimConfigfileOption) apply(p *parsedOptions) *parsedOptions {
+	p.shimConfigfile = o.shimConfigfile
+	return p
+}
+
+// ShimConfigfileOption sets the configuration file that shims pass to toolbox, so that they use it no matter which directory they're run from
+func ShimConfigfileOption(shimConfigfile string) Option {
+	return &shimConfigfileOption{shimConfigfile: shimConfigfile}
+}
+
+type isolatedOption struct {
+	isolated bool
+}
+
+func (o *isolatedOption) apply(p *parsedOptions) *parsedOptions {
+	p.isolated = o.isolated
+	return p
+}
+
+// IsolatedOption causes Do and Command to run commands with a PATH containing only the tools directory and the commands given with AllowedCommandsOption, so that nothing else on the host can be used by accident.  Like StrictOption, tracked tools must be installed.
+func IsolatedOption(isolated bool) Option {
+	return &isolatedOption{isolated: isolated}
+}
+
+type allowedCommandsOption struct {
+	allowedCommands []string
+}
+
+func (o *allowedCommandsOption) apply(p *parsedOptions) *parsedOptions {
+	p.allowedCommands = o.allowedCommands
+	return p
+}
+
+// AllowedCommandsOption sets which commands from the host PATH, such as "go", "git", or "sh", are still available to isolated commands
+func AllowedCommandsOption(allowedCommands []string) Option {
+	return &allowedCommandsOption{allowedCommands: allowedCommands}
+}
+
+type tasksOption struct {
+	tasks []*Task
+}
+
+func (o *tasksOption) apply(p *parsedOptions) *parsedOptions {
+	p.tasks = o.tasks
+	return p
+}
+
+// TasksOption sets the tasks that can be run with RunTasks.  Task names are case insensitive, as keys of the configuration file are.
+func TasksOption(tasks []*Task) Option {
+	return &tasksOption{tasks: tasks}
+}
+
 type loggerOption struct {
 	logger Logger
 }
@@ -167,7 +517,8 @@ func LoggerOption(logger Logger) Option {
 	return &loggerOption{logger: logger}
 }
 
-func parseOptions(options ...Option) (*parsedOptions, error) {
+// applyOptions applies options without calculating any defaults that require the environment, other than the names of the binaries to call
+func applyOptions(options ...Option) *parsedOptions {
 	p := &parsedOptions{}
 	for _, option := range options {
 		p = option.apply(p)
@@ -179,6 +530,34 @@ func parseOptions(options ...Option) (*parsedOptions, error) {
 	if p.goimportsBinary == "" {
 		p.goimportsBinary = defaultGoimports
 	}
+	return p
+}
+
+func parseOptions(options ...Option) (*parsedOptions, error) {
+	p := applyOptions(options...)
+
+	if p.backend == GomodBackend && p.mode != SharedMode {
+		return nil, fmt.Errorf("the %s backend can only be used in %s mode", GomodBackend, SharedMode)
+	}
+	if p.replace != "" && p.mode == GlobalMode {
+		return nil, fmt.Errorf("replacements can't be used in %s mode, as go install ignores replace directives", GlobalMode)
+	}
+	if p.replace != "" && p.dropReplace {
+		return nil, fmt.Errorf("a replacement can't be both added and dropped")
+	}
+	if p.name != "" && (strings.ContainsAny(p.name, `/\`) || p.name == "." || p.name == "..") {
+		return nil, fmt.Errorf("invalid binary name %s", p.name)
+	}
+	for _, name := range p.allowedCommands {
+		if name == "" || strings.ContainsAny(name, `/\`) {
+			return nil, fmt.Errorf("invalid allowed command %s, commands are found on the PATH and can't be paths", name)
+		}
+	}
+	for name := range p.env {
+		if !validEnvName.MatchString(name) {
+			return nil, fmt.Errorf("invalid environment variable name %s", name)
+		}
+	}
 	if p.basedirName == "" {
 		var err error
 		p.basedirName, err = defaultBasedir(p.goBinary)
@@ -189,8 +568,31 @@ func parseOptions(options ...Option) (*parsedOptions, error) {
 	if p.toolsdirName == "" {
 		p.toolsdirName = defaultToolsdir(p.basedirName)
 	}
+	if p.lockfileName == "" {
+		p.lockfileName = defaultLockfile(p.basedirName)
+	}
+	if p.artifactsName == "" {
+		p.artifactsName = defaultArtifactsfile(p.basedirName)
+	}
+	p.moduleDir = p.basedirName
+	if p.toolsmoduleName != "" {
+		p.moduleDir = p.toolsmoduleName
+		if !filepath.IsAbs(p.moduleDir) {
+			p.moduleDir = filepath.Join(p.basedirName, p.moduleDir)
+		}
+	}
 	if p.toolsfileName == "" {
-		p.toolsfileName = defaultToolsfile(p.basedirName)
+		if p.toolsmoduleName != "" {
+			p.toolsfileName = defaultToolsmoduleToolsfile(p.moduleDir)
+		} else {
+			p.toolsfileName = defaultToolsfile(p.basedirName)
+		}
+	}
+	if p.shimCommand == "" {
+		p.shimCommand = defaultShim
+	}
+	if p.jobs < 1 {
+		p.jobs = defaultJobs
 	}
 	if p.logger == nil {
 		p.logger = &defaultLogger{}
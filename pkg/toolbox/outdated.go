@@ -0,0 +1,176 @@
+package toolbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/kballard/go-shellquote"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// OutdatedTool describes the current version of a tool, alongside the newest versions available from the module proxy
+type OutdatedTool struct {
+	Package     string `json:"package"`
+	Module      string `json:"module"`
+	Version     string `json:"version"`
+	LatestPatch string `json:"latest_patch"`
+	LatestMinor string `json:"latest_minor"`
+	LatestMajor string `json:"latest_major"`
+}
+
+// Outdated queries the module proxy for newer versions of all tools.  The query is made through "go list", and therefore respects GOPROXY and friends.
+func Outdated(options ...Option) ([]*OutdatedTool, error) {
+	p, err := parseOptions(options...)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing options: %w", err)
+	}
+	return outdated(p)
+}
+
+func outdated(p *parsedOptions) ([]*OutdatedTool, error) {
+	tools, err := readTools(p)
+	if err != nil {
+		return nil, err
+	}
+
+	parseFile, err := readTrackingModfile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	retVals := make([]*OutdatedTool, len(tools))
+	queries := []string{}
+	for i, t := range tools {
+		mod, err := toolModule(t, parseFile, p)
+		if errors.Is(err, errNotPrepared) && p.mode == GlobalMode {
+			// Outdated queries the proxy anyway, so the module can be looked up without changing anything
+			m, err := resolveModule(t.Pkg, t.Version, p)
+			if err != nil {
+				return nil, err
+			}
+			mod = module.Version{Path: m.Path, Version: m.Version}
+		} else if err != nil {
+			return nil, err
+		}
+		retVals[i] = &OutdatedTool{
+			Package: t.id(),
+			Module:  mod.Path,
+			Version: mod.Version,
+		}
+		queries = append(queries, mod.String())
+	}
+	if len(queries) == 0 {
+		return retVals, nil
+	}
+
+	versions, err := listModuleVersions(queries, p)
+	if err != nil {
+		return nil, err
+	}
+
+	latestMajors := map[string]string{}
+	for _, o := range retVals {
+		o.LatestPatch = o.Version
+		o.LatestMinor = o.Version
+		for _, v := range versions[o.Module] {
+			if semver.Prerelease(v) != "" {
+				continue
+			}
+			if semver.MajorMinor(v) == semver.MajorMinor(o.Version) && semver.Compare(v, o.LatestPatch) > 0 {
+				o.LatestPatch = v
+			}
+			if semver.Major(v) == semver.Major(o.Version) && semver.Compare(v, o.LatestMinor) > 0 {
+				o.LatestMinor = v
+			}
+		}
+
+		latestMajor, ok := latestMajors[o.Module]
+		if !ok {
+			latestMajor = latestMajorVersion(o.Module, p)
+			latestMajors[o.Module] = latestMajor
+		}
+		o.LatestMajor = o.LatestMinor
+		if latestMajor != "" {
+			o.LatestMajor = latestMajor
+		}
+	}
+
+	return retVals, nil
+}
+
+type listedModule struct {
+	Path     string   `json:"Path"`
+	Version  string   `json:"Version"`
+	Versions []string `json:"Versions"`
+}
+
+// listModuleVersions asks go for all known versions of the given modules.  Modules are given as path@version queries, so that they don't need to be part of any build list.
+func listModuleVersions(queries []string, p *parsedOptions) (map[string][]string, error) {
+	modules, err := goListModules(append([]string{"-versions"}, queries...), p)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[string][]string{}
+	for _, m := range modules {
+		versions[m.Path] = m.Versions
+	}
+	return versions, nil
+}
+
+// latestMajorVersion searches for newer major versions of a module, by querying successive /vN module paths until one isn't found.  Returns the empty string if no newer major version exists.
+func latestMajorVersion(modulePath string, p *parsedOptions) string {
+	prefix, pathMajor, ok := module.SplitPathVersion(modulePath)
+	if !ok || strings.HasPrefix(pathMajor, ".") {
+		// gopkg.in style paths can't be searched this way
+		return ""
+	}
+
+	major := 1
+	if pathMajor != "" {
+		var err error
+		major, err = strconv.Atoi(strings.TrimPrefix(pathMajor, "/v"))
+		if err != nil {
+			return ""
+		}
+	}
+
+	latest := ""
+	for {
+		major++
+		modules, err := goListModules([]string{fmt.Sprintf("%s/v%d@latest", prefix, major)}, p)
+		if err != nil || len(modules) == 0 {
+			return latest
+		}
+		latest = modules[0].Version
+	}
+}
+
+func goListModules(args []string, p *parsedOptions) ([]*listedModule, error) {
+	golist := goCommand(p, append([]string{"list", "-m", "-json"}, args...)...)
+	golist.Stderr = newLogWriter(p.logger)
+	p.logger.Printf("calling \"%s\"", shellquote.Join(golist.Args...))
+	out, err := golist.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error calling go list: %w", err)
+	}
+
+	modules := []*listedModule{}
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	for {
+		m := &listedModule{}
+		if err := decoder.Decode(m); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error parsing go list output: %w", err)
+		}
+		modules = append(modules, m)
+	}
+	return modules, nil
+}
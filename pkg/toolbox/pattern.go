@@ -0,0 +1,122 @@
+package toolbox
+
+import (
+	"errors"
+	"fmt"
+	"go/build"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// isPattern reports whether packageName is a package pattern, such as "golang.org/x/tools/cmd/..."
+func isPattern(packageName string) bool {
+	return strings.Contains(packageName, "...")
+}
+
+// matchPattern returns a function that reports whether a package matches pattern.  As with the go command, "..." matches any string, and a trailing "/..." also matches the path before it.
+func matchPattern(pattern string) func(string) bool {
+	re := regexp.QuoteMeta(pattern)
+	re = strings.ReplaceAll(re, `\.\.\.`, `.*`)
+	if strings.HasSuffix(re, `/.*`) {
+		re = strings.TrimSuffix(re, `/.*`) + `(/.*)?`
+	}
+	reg := regexp.MustCompile(`^` + re + `$`)
+	return reg.MatchString
+}
+
+// patternRoot returns the longest package path that every package matching pattern is inside of, which is used to find the module providing them
+func patternRoot(pattern string) string {
+	root := pattern[:strings.Index(pattern, "...")]
+	if strings.HasSuffix(root, "/") {
+		return strings.TrimSuffix(root, "/")
+	}
+	return path.Dir(root)
+}
+
+// expandPattern finds every main package matching pattern, within the module providing the pattern at the given version query
+func expandPattern(pattern, version string, p *parsedOptions) ([]string, module.Version, error) {
+	m, err := resolveModule(patternRoot(pattern), version, p)
+	if err != nil {
+		return nil, module.Version{}, err
+	}
+	mod := module.Version{Path: m.Path, Version: m.Version}
+	downloaded, err := downloadModule(mod, p)
+	if err != nil {
+		return nil, module.Version{}, err
+	}
+
+	pkgs, err := findCommands(downloaded.Dir, mod.Path, pattern)
+	if err != nil {
+		return nil, module.Version{}, fmt.Errorf("error searching %s for packages matching %s: %w", mod, pattern, err)
+	}
+	if len(pkgs) == 0 {
+		return nil, module.Version{}, fmt.Errorf("no commands found matching %s in %s", pattern, mod)
+	}
+	return pkgs, mod, nil
+}
+
+// findCommands finds every main package matching pattern in the module at modPath, whose files are in dir
+func findCommands(dir, modPath, pattern string) ([]string, error) {
+	match := matchPattern(pattern)
+	pkgs := []string{}
+	err := filepath.Walk(dir, func(pkgDir string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, pkgDir)
+		if err != nil {
+			return err
+		}
+		if rel != "." {
+			// Skip directories that go itself ignores, and nested modules
+			name := info.Name()
+			if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor" {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(pkgDir, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+		}
+
+		pkg := modPath
+		if rel != "." {
+			pkg = modPath + "/" + filepath.ToSlash(rel)
+		}
+		if !match(pkg) {
+			return nil
+		}
+		isMain, err := isMainPackage(pkgDir)
+		if err != nil {
+			return err
+		}
+		if isMain {
+			pkgs = append(pkgs, pkg)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pkgs, nil
+}
+
+// isMainPackage reports whether the go files in dir that match the current build context, as the go command would select them by their build constraints and file names, make up a command
+func isMainPackage(dir string) (bool, error) {
+	pkg, err := build.ImportDir(dir, 0)
+	var noGo *build.NoGoError
+	if errors.As(err, &noGo) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("error reading package in %s: %w", dir, err)
+	}
+	return pkg.Name == "main", nil
+}
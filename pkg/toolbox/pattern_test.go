@@ -0,0 +1,95 @@
+package toolbox
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		pkg     string
+		matches bool
+	}{
+		{pattern: "example.com/tools/...", pkg: "example.com/tools", matches: true},
+		{pattern: "example.com/tools/...", pkg: "example.com/tools/cmd/foo", matches: true},
+		{pattern: "example.com/tools/...", pkg: "example.com/toolsx", matches: false},
+		{pattern: "example.com/tools/cmd/...", pkg: "example.com/tools/cmd", matches: true},
+		{pattern: "example.com/tools/cmd/...", pkg: "example.com/tools/cmd/foo/bar", matches: true},
+		{pattern: "example.com/tools/cmd/...", pkg: "example.com/tools/internal/foo", matches: false},
+		{pattern: "example.com/tools/cmd/go...", pkg: "example.com/tools/cmd/goimports", matches: true},
+		{pattern: "example.com/tools/cmd/go...", pkg: "example.com/tools/cmd/stringer", matches: false},
+		{pattern: "example.com/tools/.../gen", pkg: "example.com/tools/cmd/x/gen", matches: true},
+		{pattern: "example.com/tools/.../gen", pkg: "example.com/tools/cmd/x/gen/more", matches: false},
+		// Regular expression syntax is matched literally
+		{pattern: "example.com/t.ols/...", pkg: "example.com/tools/cmd", matches: false},
+	}
+	for _, test := range tests {
+		t.Run(test.pattern+" "+test.pkg, func(t *testing.T) {
+			if matches := matchPattern(test.pattern)(test.pkg); matches != test.matches {
+				t.Errorf("expected %v, got %v", test.matches, matches)
+			}
+		})
+	}
+}
+
+func TestPatternRoot(t *testing.T) {
+	tests := map[string]string{
+		"example.com/tools/...":        "example.com/tools",
+		"example.com/tools/cmd/...":    "example.com/tools/cmd",
+		"example.com/tools/cmd/go...":  "example.com/tools/cmd",
+		"example.com/tools/.../gen":    "example.com/tools",
+		"example.com/tools/cmd/.../go": "example.com/tools/cmd",
+	}
+	for pattern, expected := range tests {
+		t.Run(pattern, func(t *testing.T) {
+			if root := patternRoot(pattern); root != expected {
+				t.Errorf("expected %s, got %s", expected, root)
+			}
+		})
+	}
+}
+
+func TestFindCommands(t *testing.T) {
+	dir := t.TempDir()
+	writeTestModule(t, dir, map[string]string{
+		"go.mod":                    "module example.com/tools\n",
+		"main.go":                   "package main\n",
+		"cmd/foo/main.go":           "package main\n",
+		"cmd/foo/main_test.go":      "package main_test\n",
+		"cmd/bar/bar.go":            "package bar\n",
+		"cmd/bar/internal/main.go":  "package main\n",
+		"cmd/ignored/gen.go":        "//go:build ignore\n\npackage main\n",
+		"cmd/ignored/lib.go":        "package lib\n",
+		"cmd/testdata/x/main.go":    "package main\n",
+		"cmd/vendor/x/main.go":      "package main\n",
+		"vendor/example.com/y/y.go": "package main\n",
+		"cmd/_hidden/main.go":       "package main\n",
+		"cmd/.git/main.go":          "package main\n",
+		"cmd/nested/go.mod":         "module example.com/tools/cmd/nested\n",
+		"cmd/nested/main.go":        "package main\n",
+		"cmd/empty/README":          "no go files\n",
+	})
+
+	tests := []struct {
+		pattern  string
+		expected []string
+	}{
+		{pattern: "example.com/tools/...", expected: []string{"example.com/tools", "example.com/tools/cmd/bar/internal", "example.com/tools/cmd/foo"}},
+		{pattern: "example.com/tools/cmd/...", expected: []string{"example.com/tools/cmd/bar/internal", "example.com/tools/cmd/foo"}},
+		{pattern: "example.com/tools/cmd/f...", expected: []string{"example.com/tools/cmd/foo"}},
+		{pattern: "example.com/tools/cmd/bar/...", expected: []string{"example.com/tools/cmd/bar/internal"}},
+		{pattern: "example.com/tools/cmd/ignored/...", expected: []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.pattern, func(t *testing.T) {
+			pkgs, err := findCommands(dir, "example.com/tools", test.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(pkgs, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, pkgs)
+			}
+		})
+	}
+}
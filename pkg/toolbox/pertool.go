@@ -0,0 +1,102 @@
+package toolbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kballard/go-shellquote"
+	"golang.org/x/mod/module"
+)
+
+// modulesDir is the directory inside of the tools directory where per-tool modules are generated.  The leading dot keeps go from treating it as a package.
+const modulesDir = ".modules"
+
+// perToolModuleDir returns the directory of the generated module that an isolated tool is built from
+func perToolModuleDir(t *tool, p *parsedOptions) (string, error) {
+	escaped, err := module.EscapePath(t.Pkg)
+	if err != nil {
+		return "", fmt.Errorf("error escaping package path %s: %w", t.Pkg, err)
+	}
+	if t.Alias != "" {
+		escaped += "@" + t.Alias
+	}
+	return filepath.Join(p.toolsdirName, modulesDir, filepath.FromSlash(escaped)), nil
+}
+
+// toolModuleDir returns the directory of the module that a tool is built from
+func toolModuleDir(t *tool, p *parsedOptions) (string, error) {
+	if isolated(t, p) {
+		return perToolModuleDir(t, p)
+	}
+	return p.moduleDir, nil
+}
+
+// preparePerToolModule generates the module that an isolated tool is built from, and makes sure that it requires the given version of the tool.  An empty version selects the latest.  Returns the module and version that was resolved.
+func preparePerToolModule(t *tool, version string, p *parsedOptions, logger Logger) (module.Version, error) {
+	dir, err := perToolModuleDir(t, p)
+	if err != nil {
+		return module.Version{}, err
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			return module.Version{}, fmt.Errorf("error creating tool module directory %s: %w", dir, err)
+		}
+		gomod := goCommand(p, "mod", "init", toolsModulePath)
+		gomod.Dir = dir
+		gomod.Stdout = newLogWriter(logger)
+		gomod.Stderr = newLogWriter(logger)
+		logger.Printf("calling \"%s\" in %s", shellquote.Join(gomod.Args...), dir)
+		if err := gomod.Run(); err != nil {
+			return module.Version{}, fmt.Errorf("error calling go mod init for %s: %w", t.Pkg, err)
+		}
+	} else if version != "" {
+		parseFile, err := readModfileIn(dir)
+		if err != nil {
+			return module.Version{}, err
+		}
+		if req := findRequire(t.Pkg, parseFile); req != nil && req.Mod.Version == version {
+			return req.Mod, perToolReplace(t, dir, p, logger)
+		}
+	}
+
+	pkgVer := t.Pkg
+	if version != "" {
+		pkgVer = t.Pkg + "@" + version
+	}
+	args := []string{"get", "-v"}
+	flags, err := buildFlagArgs(t.BuildFlags)
+	if err != nil {
+		return module.Version{}, err
+	}
+	args = append(args, flags...)
+	args = append(args, pkgVer)
+
+	goget := goCommand(p, args...)
+	goget.Dir = dir
+	goget.Stdout = newLogWriter(logger)
+	goget.Stderr = newLogWriter(logger)
+	logger.Printf("calling \"%s\" in %s", shellquote.Join(goget.Args...), dir)
+	if err := goget.Run(); err != nil {
+		return module.Version{}, fmt.Errorf("error calling go get for %s: %w", t.Pkg, err)
+	}
+
+	parseFile, err := readModfileIn(dir)
+	if err != nil {
+		return module.Version{}, err
+	}
+	req := findRequire(t.Pkg, parseFile)
+	if req == nil {
+		return module.Version{}, fmt.Errorf("no version for package %s found", t.Pkg)
+	}
+	return req.Mod, perToolReplace(t, dir, p, logger)
+}
+
+// perToolReplace applies a tool's recorded replacement to its generated module, or drops a replacement that is no longer recorded
+func perToolReplace(t *tool, dir string, p *parsedOptions, logger Logger) error {
+	if t.Replace == "" {
+		return dropReplace(t.Pkg, dir, p, logger)
+	}
+	return addReplace(t.Pkg, t.Replace, dir, p, logger)
+}
@@ -3,36 +3,78 @@ package toolbox
 import (
 	"fmt"
 	"os"
-	"os/exec"
-	"path"
-	"path/filepath"
 
 	"github.com/kballard/go-shellquote"
 )
 
-// Remove stops tracking the tool from packageName in our vendoring system.
+// Remove stops tracking the tool from packageName in our vendoring system, along with any side-by-side versions of it.  A single side-by-side version may be removed with "pkg@alias", and an artifact may be removed by name.  packageName may also be a pattern such as "golang.org/x/tools/...", in which case every tracked tool matching the pattern is removed.
 func Remove(packageName string, options ...Option) error {
 	p, err := parseOptions(options...)
 	if err != nil {
 		return fmt.Errorf("error parsing options: %w", err)
 	}
 
+	if !isPattern(packageName) {
+		if removed, err := removeArtifact(packageName, p); err != nil {
+			return err
+		} else if removed {
+			return nil
+		}
+	}
+
 	tools, err := readTools(p)
 	if err != nil {
 		return err
 	}
 
-	for i, tool := range tools {
-		if tool.Pkg == packageName {
-			tools[len(tools)-1], tools[i] = tools[i], tools[len(tools)-1]
-			if err := writeTools(tools[:len(tools)-1], p); err != nil {
-				return err
-			}
-			break
+	pkg, alias := splitAlias(packageName)
+	match := func(t *tool) bool { return t.Pkg == pkg && (alias == "" || t.Alias == alias) }
+	if isPattern(packageName) {
+		matchPkg := matchPattern(packageName)
+		match = func(t *tool) bool { return matchPkg(t.Pkg) }
+	}
+
+	removed := []*tool{}
+	remaining := []*tool{}
+	for _, t := range tools {
+		if match(t) {
+			removed = append(removed, t)
+		} else {
+			remaining = append(remaining, t)
+		}
+	}
+	if len(removed) > 0 {
+		if err := writeTools(remaining, p); err != nil {
+			return err
+		}
+	}
+	if !isPattern(packageName) && len(removed) == 0 {
+		// Even untracked tools have their binary cleaned up
+		removed = []*tool{{Pkg: pkg, Alias: alias}}
+	}
+
+	for _, t := range removed {
+		if err := removeInstalledTool(t, p); err != nil {
+			return err
 		}
 	}
-	_, dependencyPkg := path.Split(packageName)
-	dependencyFile := filepath.Join(p.toolsdirName, dependencyPkg)
+
+	if p.mode == SharedMode {
+		gomod := goCommand(p, "mod", "tidy", "-v")
+		gomod.Stdout = newLogWriter(p.logger)
+		gomod.Stderr = newLogWriter(p.logger)
+		p.logger.Printf("calling \"%s\"", shellquote.Join(gomod.Args...))
+		if err := gomod.Run(); err != nil {
+			return fmt.Errorf("error calling go mod tidy: %w", err)
+		}
+	}
+
+	return writeLockfile(remaining, nil, p)
+}
+
+// removeInstalledTool deletes everything installed into the tools directory for a tool
+func removeInstalledTool(t *tool, p *parsedOptions) error {
+	dependencyFile := binaryPath(t, p)
 	if _, err := os.Stat(dependencyFile); !os.IsNotExist(err) {
 		p.logger.Printf("removing file %s", dependencyFile)
 		if err := os.Remove(dependencyFile); err != nil {
@@ -41,14 +83,19 @@ func Remove(packageName string, options ...Option) error {
 	} else {
 		p.logger.Printf("could not find file %s for removal", dependencyFile)
 	}
-
-	gomod := exec.Command(p.goBinary, "mod", "tidy", "-v")
-	gomod.Stdout = newLogWriter(p.logger)
-	gomod.Stderr = newLogWriter(p.logger)
-	p.logger.Printf("calling \"%s\"", shellquote.Join(gomod.Args...))
-	if err := gomod.Run(); err != nil {
-		return fmt.Errorf("error calling go mod tidy: %w", err)
+	if err := removeStamp(t.id(), p); err != nil {
+		return err
 	}
 
+	if isolated(t, p) {
+		moduleDir, err := perToolModuleDir(t, p)
+		if err != nil {
+			return err
+		}
+		p.logger.Printf("removing tool module %s", moduleDir)
+		if err := os.RemoveAll(moduleDir); err != nil {
+			return fmt.Errorf("error deleting tool module: %w", err)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,127 @@
+package toolbox
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/kballard/go-shellquote"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// parseReplacement parses the target of a replace directive, which is either a local directory or a module path and version in the form "module@version".  Local directories are resolved relative to basedir, then made relative to dir, the directory of the go.mod that the directive is written to.
+func parseReplacement(replace, dir string, p *parsedOptions) (module.Version, error) {
+	if modfile.IsDirectoryPath(replace) {
+		if filepath.IsAbs(replace) {
+			return module.Version{Path: replace}, nil
+		}
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return module.Version{}, fmt.Errorf("error finding absolute path to %s: %w", dir, err)
+		}
+		absReplace, err := filepath.Abs(filepath.Join(p.basedirName, replace))
+		if err != nil {
+			return module.Version{}, fmt.Errorf("error finding absolute path to %s: %w", replace, err)
+		}
+		rel, err := filepath.Rel(absDir, absReplace)
+		if err != nil {
+			return module.Version{}, fmt.Errorf("error finding replacement %s relative to %s: %w", replace, dir, err)
+		}
+		rel = filepath.ToSlash(rel)
+		if !strings.HasPrefix(rel, "../") {
+			rel = "./" + rel
+		}
+		return module.Version{Path: rel}, nil
+	}
+
+	i := strings.LastIndex(replace, "@")
+	if i < 0 {
+		return module.Version{}, fmt.Errorf("replacement %s must be a local directory or in the form module@version", replace)
+	}
+	mod := module.Version{Path: replace[:i], Version: replace[i+1:]}
+	if err := module.CheckPath(mod.Path); err != nil {
+		return module.Version{}, fmt.Errorf("invalid replacement module %s: %w", mod.Path, err)
+	}
+	return mod, nil
+}
+
+// addReplace writes a directive to the go.mod in dir, replacing every version of the module providing pkg.  The module is re-required at its current version afterwards, so that go.sum picks up the replacement.
+func addReplace(pkg, replace, dir string, p *parsedOptions, logger Logger) error {
+	parseFile, err := readModfileIn(dir)
+	if err != nil {
+		return err
+	}
+	req := findRequire(pkg, parseFile)
+	if req == nil {
+		return fmt.Errorf("no module providing package %s found in %s", pkg, dir)
+	}
+	replacement, err := parseReplacement(replace, dir, p)
+	if err != nil {
+		return err
+	}
+
+	if rep := findReplace(req.Mod, parseFile); rep != nil && rep.Old.Version == "" && rep.New == replacement {
+		return nil
+	}
+	if err := parseFile.AddReplace(req.Mod.Path, "", replacement.Path, replacement.Version); err != nil {
+		return fmt.Errorf("error adding replacement for %s: %w", req.Mod.Path, err)
+	}
+	parseFile.Cleanup()
+	filename := filepath.Join(dir, "go.mod")
+	if err := ioutil.WriteFile(filename, modfile.Format(parseFile.Syntax), 0666); err != nil {
+		return fmt.Errorf("error writing modfile %s: %w", filename, err)
+	}
+
+	goget := goCommand(p, "get", pkg+"@"+req.Mod.Version)
+	goget.Dir = dir
+	goget.Stdout = newLogWriter(logger)
+	goget.Stderr = newLogWriter(logger)
+	logger.Printf("calling \"%s\" in %s", shellquote.Join(goget.Args...), dir)
+	if err := goget.Run(); err != nil {
+		return fmt.Errorf("error calling go get for replacement of %s: %w", pkg, err)
+	}
+	return nil
+}
+
+// dropReplace removes every directive from the go.mod in dir that replaces the module providing pkg.  As with addReplace, the module is re-required at its current version afterwards, so that go.sum matches the module itself again.
+func dropReplace(pkg, dir string, p *parsedOptions, logger Logger) error {
+	parseFile, err := readModfileIn(dir)
+	if err != nil {
+		return err
+	}
+	req := findRequire(pkg, parseFile)
+	if req == nil {
+		return fmt.Errorf("no module providing package %s found in %s", pkg, dir)
+	}
+
+	dropped := false
+	for _, rep := range append([]*modfile.Replace{}, parseFile.Replace...) {
+		if rep.Old.Path != req.Mod.Path {
+			continue
+		}
+		if err := parseFile.DropReplace(rep.Old.Path, rep.Old.Version); err != nil {
+			return fmt.Errorf("error dropping replacement for %s: %w", req.Mod.Path, err)
+		}
+		dropped = true
+	}
+	if !dropped {
+		return nil
+	}
+	parseFile.Cleanup()
+	filename := filepath.Join(dir, "go.mod")
+	if err := ioutil.WriteFile(filename, modfile.Format(parseFile.Syntax), 0666); err != nil {
+		return fmt.Errorf("error writing modfile %s: %w", filename, err)
+	}
+
+	goget := goCommand(p, "get", pkg+"@"+req.Mod.Version)
+	goget.Dir = dir
+	goget.Stdout = newLogWriter(logger)
+	goget.Stderr = newLogWriter(logger)
+	logger.Printf("calling \"%s\" in %s", shellquote.Join(goget.Args...), dir)
+	if err := goget.Run(); err != nil {
+		return fmt.Errorf("error calling go get after dropping replacement of %s: %w", pkg, err)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package toolbox
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/kballard/go-shellquote"
+)
+
+// shimMarker identifies files written by Shims, so that stale shims can be removed without touching anything else in the directory
+const shimMarker = "Generated by toolbox shims.  DO NOT EDIT."
+
+// Shims writes a small wrapper script into dir for every tracked tool and artifact.  Each script runs the vendored tool through "toolbox do", with the toolbox executable given by ShimCommandOption, which installs it first if it's missing or out of date, so dir can be put on the PATH in place of the tools directory.  Scripts find the project relative to their own location, so dir should be inside of the project.  Shims previously written to dir for tools that are no longer tracked are removed.  Relative directories are relative to the base directory.
+func Shims(dir string, options ...Option) error {
+	p, err := parseOptions(options...)
+	if err != nil {
+		return fmt.Errorf("error parsing options: %w", err)
+	}
+
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(p.basedirName, dir)
+	}
+
+	tools, err := readTools(p)
+	if err != nil {
+		return err
+	}
+	artifacts, err := readArtifacts(p)
+	if err != nil {
+		return err
+	}
+
+	toolboxArgs, err := shimArgs(dir, p)
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for _, t := range tools {
+		names = append(names, strings.TrimSuffix(binaryName(t), ".exe"))
+	}
+	for _, a := range artifacts {
+		names = append(names, strings.TrimSuffix(a.Name, ".exe"))
+	}
+
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return fmt.Errorf("error creating shim directory %s: %w", dir, err)
+	}
+
+	wanted := map[string]bool{}
+	for _, name := range names {
+		filename := filepath.Join(dir, shimFilename(name))
+		wanted[filepath.Base(filename)] = true
+		if existing, err := os.Stat(filename); err == nil && !existing.IsDir() && !isShim(filename) {
+			return fmt.Errorf("refusing to overwrite %s, which wasn't written by toolbox", filename)
+		}
+		p.logger.Printf("writing shim %s", filename)
+		if err := ioutil.WriteFile(filename, []byte(shimScript(name, toolboxArgs)), 0777); err != nil {
+			return fmt.Errorf("error writing shim %s: %w", filename, err)
+		}
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("error reading shim directory %s: %w", dir, err)
+	}
+	for _, file := range files {
+		filename := filepath.Join(dir, file.Name())
+		if file.IsDir() || wanted[file.Name()] || !isShim(filename) {
+			continue
+		}
+		p.logger.Printf("removing stale shim %s", filename)
+		if err := os.Remove(filename); err != nil {
+			return fmt.Errorf("error removing stale shim %s: %w", filename, err)
+		}
+	}
+	return nil
+}
+
+// shimArg is one argument of the toolbox command line that shims run.  Arguments that are relative are paths relative to the shim directory, which shims resolve from their own location when they run.
+type shimArg struct {
+	value    string
+	relative bool
+}
+
+// shimArgs returns the toolbox command line that shims run, which passes every resolved location, the configuration file, and how do runs tools explicitly so that shims work from any directory.  Locations are given relative to dir, so that the project can be moved or checked out somewhere else without writing the shims again.
+func shimArgs(dir string, p *parsedOptions) ([]shimArg, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error finding absolute path to %s: %w", dir, err)
+	}
+	location := func(name string) (shimArg, error) {
+		absName, err := filepath.Abs(name)
+		if err != nil {
+			return shimArg{}, fmt.Errorf("error finding absolute path to %s: %w", name, err)
+		}
+		// Paths on another drive can't be made relative
+		rel, err := filepath.Rel(absDir, absName)
+		if err != nil {
+			return shimArg{value: absName}, nil
+		}
+		return shimArg{value: rel, relative: true}, nil
+	}
+
+	args := []shimArg{{value: p.shimCommand}}
+	for _, flag := range []struct {
+		name  string
+		value string
+	}{
+		{"base_dir", p.basedirName},
+		{"tools_file", p.toolsfileName},
+		{"tools_directory", p.toolsdirName},
+		{"lock_file", p.lockfileName},
+		{"artifacts_file", p.artifactsName},
+	} {
+		value, err := location(flag.value)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, shimArg{value: "--" + flag.name}, value)
+	}
+	if p.toolsmoduleName != "" {
+		moduleDir, err := location(p.moduleDir)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, shimArg{value: "--tools_module"}, moduleDir)
+	}
+	if p.shimConfigfile != "" {
+		configfile, err := location(p.shimConfigfile)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, shimArg{value: "--config_file"}, configfile)
+	}
+	if p.goBinary != defaultGo {
+		args = append(args, shimArg{value: "--go"}, shimArg{value: p.goBinary})
+	}
+	for _, arg := range []string{"--mode", p.mode.String(), "--backend", p.backend.String(), "do"} {
+		args = append(args, shimArg{value: arg})
+	}
+	if p.strict {
+		args = append(args, shimArg{value: "--strict"})
+	}
+	if p.isolated {
+		args = append(args, shimArg{value: "--isolated"})
+	}
+	if len(p.allowedCommands) > 0 {
+		args = append(args, shimArg{value: "--allowed_commands"}, shimArg{value: strings.Join(p.allowedCommands, ",")})
+	}
+	return append(args, shimArg{value: "--"}), nil
+}
+
+func shimFilename(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".cmd"
+	}
+	return name
+}
+
+func shimScript(name string, toolboxArgs []shimArg) string {
+	if runtime.GOOS == "windows" {
+		// %~dp0 is the directory of the script, with a trailing separator
+		quoted := make([]string, len(toolboxArgs))
+		for i, arg := range toolboxArgs {
+			if arg.relative {
+				quoted[i] = "\"%~dp0" + arg.value + "\""
+			} else {
+				quoted[i] = "\"" + arg.value + "\""
+			}
+		}
+		return fmt.Sprintf("@echo off\r\nrem %s\r\n%s \"%s\" %%*\r\n", shimMarker, strings.Join(quoted, " "), name)
+	}
+	quoted := make([]string, len(toolboxArgs))
+	for i, arg := range toolboxArgs {
+		if arg.relative {
+			quoted[i] = "\"$dir\"/" + shellquote.Join(filepath.ToSlash(arg.value))
+		} else {
+			quoted[i] = shellquote.Join(arg.value)
+		}
+	}
+	return fmt.Sprintf("#!/bin/sh\n# %s\ndir=$(cd \"$(dirname \"$0\")\" && pwd)\nexec %s %s \"$@\"\n", shimMarker, strings.Join(quoted, " "), shellquote.Join(name))
+}
+
+// isShim reports whether the file at filename was written by Shims, by looking for the marker in its first few lines
+func isShim(filename string) bool {
+	file, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for i := 0; i < 3 && scanner.Scan(); i++ {
+		if strings.Contains(scanner.Text(), shimMarker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package toolbox
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeToolbox writes a script that prints each of its arguments on a line of its own, in place of toolbox
+func fakeToolbox(t *testing.T) string {
+	filename := filepath.Join(t.TempDir(), "toolbox")
+	writeTestModule(t, filepath.Dir(filename), map[string]string{
+		"toolbox": "#!/bin/sh\nfor arg in \"$@\"; do echo \"$arg\"; done\n",
+	})
+	if err := os.Chmod(filename, 0777); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestShims(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shims are batch files on windows")
+	}
+
+	dir := filepath.Join(t.TempDir(), "my 'project'")
+	configfile := filepath.Join(dir, "config", ".toolbox.yaml")
+	writeTestModule(t, dir, map[string]string{"config/.toolbox.yaml": "mode: shared\n"})
+	writeTestTools(t, dir, &tool{Pkg: "example.com/hello", Version: "v1.0.0"})
+
+	toolbox := fakeToolbox(t)
+	options := []Option{
+		BasedirOption(dir),
+		ShimCommandOption(toolbox),
+		ShimConfigfileOption(configfile),
+		StrictOption(true),
+		IsolatedOption(true),
+		AllowedCommandsOption([]string{"go", "git"}),
+	}
+	if err := Shims("bin", options...); err != nil {
+		t.Fatalf("error writing shims: %v", err)
+	}
+	p, err := parseOptions(options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Shims must not depend on the directory they're run from
+	shim := exec.Command(filepath.Join(dir, "bin", "hello"), "first arg", "--second")
+	shim.Dir = t.TempDir()
+	out, err := shim.Output()
+	if err != nil {
+		t.Fatalf("error running shim: %v", err)
+	}
+	args := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	for i, arg := range args {
+		if filepath.IsAbs(arg) {
+			args[i] = filepath.Clean(arg)
+		}
+	}
+
+	expected := []string{
+		"--base_dir", dir,
+		"--tools_file", p.toolsfileName,
+		"--tools_directory", p.toolsdirName,
+		"--lock_file", p.lockfileName,
+		"--artifacts_file", p.artifactsName,
+		"--config_file", configfile,
+		"--mode", "shared",
+		"--backend", "toolsfile",
+		"do", "--strict", "--isolated", "--allowed_commands", "go,git", "--", "hello", "first arg", "--second",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("shim ran toolbox with\n%q\nexpected\n%q", args, expected)
+	}
+}
@@ -0,0 +1,175 @@
+package toolbox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// stampsDir is the directory inside of the tools directory where install stamps are kept.  The leading dot keeps go from treating it as a package.
+const stampsDir = ".stamps"
+
+// stamp records the inputs used to build a tool binary.  If none of these have changed since the last install, the tool does not need to be rebuilt.
+type stamp struct {
+	Package    string `json:"package"`
+	Version    string `json:"version"`
+	BuildFlags string `json:"build_flags"`
+	Name       string `json:"name,omitempty"`
+	GoVersion  string `json:"go_version"`
+	// Replace is the replacement the tool's module is built from, if any.  For a local directory, ReplaceHash is a hash of its contents, so that edits to a fork cause a rebuild.
+	Replace     string `json:"replace,omitempty"`
+	ReplaceHash string `json:"replace_hash,omitempty"`
+}
+
+func newStamp(t *tool, parseFile *modfile.File, goVersion string, p *parsedOptions) (*stamp, error) {
+	s := &stamp{
+		Package:    t.id(),
+		Version:    trackedVersion(t, parseFile),
+		BuildFlags: t.BuildFlags,
+		Name:       t.Name,
+		GoVersion:  goVersion,
+	}
+
+	replace, dir := stampReplacement(t, parseFile, p)
+	s.Replace = replace
+	if dir != "" {
+		hash, err := hashDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		s.ReplaceHash = hash
+	}
+	return s, nil
+}
+
+// stampReplacement returns the replacement that a tool is built from, and the directory it's found in if it's local
+func stampReplacement(t *tool, parseFile *modfile.File, p *parsedOptions) (string, string) {
+	if isolated(t, p) {
+		if t.Replace == "" || !modfile.IsDirectoryPath(t.Replace) {
+			return t.Replace, ""
+		}
+		if filepath.IsAbs(t.Replace) {
+			return t.Replace, t.Replace
+		}
+		return t.Replace, filepath.Join(p.basedirName, t.Replace)
+	}
+	if parseFile == nil {
+		return "", ""
+	}
+	req := findRequire(t.Pkg, parseFile)
+	if req == nil {
+		return "", ""
+	}
+	rep := findReplace(req.Mod, parseFile)
+	if rep == nil {
+		return "", ""
+	}
+	if rep.New.Version != "" {
+		return rep.New.Path + "@" + rep.New.Version, ""
+	}
+	if filepath.IsAbs(rep.New.Path) {
+		return rep.New.Path, rep.New.Path
+	}
+	return rep.New.Path, filepath.Join(p.moduleDir, rep.New.Path)
+}
+
+// hashDir returns a hash of the names and contents of every file in dir, skipping version control directories
+func hashDir(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", filepath.ToSlash(rel), len(contents))
+		h.Write(contents)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("error hashing replacement directory %s: %w", dir, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// stampFile returns the location of the stamp for the given package.  Stamps are addressed by the hash of the package name, so that they are safe to use as file names.
+func stampFile(pkg string, p *parsedOptions) string {
+	sum := sha256.Sum256([]byte(pkg))
+	return filepath.Join(p.toolsdirName, stampsDir, hex.EncodeToString(sum[:])+".json")
+}
+
+// isCurrent checks to see if the tool was already built with the inputs recorded in s, and that the tool binary still exists
+func (s *stamp) isCurrent(t *tool, p *parsedOptions) bool {
+	if s.Version == "" {
+		// Without a version, we have no way of knowing what was built
+		return false
+	}
+
+	if _, err := os.Stat(binaryPath(t, p)); err != nil {
+		return false
+	}
+
+	bytes, err := ioutil.ReadFile(stampFile(s.Package, p))
+	if err != nil {
+		return false
+	}
+	existing := &stamp{}
+	if err := json.Unmarshal(bytes, existing); err != nil {
+		return false
+	}
+	return *existing == *s
+}
+
+func (s *stamp) write(p *parsedOptions) error {
+	filename := stampFile(s.Package, p)
+	if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+		return fmt.Errorf("error creating stamp directory: %w", err)
+	}
+	bytes, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("error marshalling stamp for %s: %w", s.Package, err)
+	}
+	if err := ioutil.WriteFile(filename, bytes, 0666); err != nil {
+		return fmt.Errorf("error writing stamp file %s: %w", filename, err)
+	}
+	return nil
+}
+
+func removeStamp(pkg string, p *parsedOptions) error {
+	if err := os.Remove(stampFile(pkg, p)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing stamp for %s: %w", pkg, err)
+	}
+	return nil
+}
+
+// goVersion returns the version of the go toolchain, as reported by "go version"
+func goVersion(p *parsedOptions) (string, error) {
+	out, err := exec.Command(p.goBinary, "version").Output()
+	if err != nil {
+		return "", fmt.Errorf("error finding go version: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
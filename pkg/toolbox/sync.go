@@ -1,48 +1,196 @@
 package toolbox
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
+	"strings"
+	"sync"
 
 	"github.com/kballard/go-shellquote"
 )
 
-// Sync interates through all tools that we're vendoring, and ensures that all of them are installed, and at the correct version.
+// SyncError is returned by Sync when one or more tools fail to install.  Every failure is collected, rather than stopping at the first.
+type SyncError struct {
+	Errors []error
+}
+
+func (e *SyncError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d tool(s) failed to install:\n%s", len(e.Errors), strings.Join(msgs, "\n"))
+}
+
+// Sync interates through all tools that we're vendoring, and ensures that all of them are installed, and at the correct version.  Artifacts are downloaded and verified as well.
 func Sync(options ...Option) error {
 	p, err := parseOptions(options...)
 	if err != nil {
 		return fmt.Errorf("error parsing options: %w", err)
 	}
-	tools, err := readTools(p)
+	return syncTools(p, nil)
+}
+
+// syncTools installs the tools and artifacts named in only, which holds tool ids and artifact names.  If only is nil, everything is installed.
+func syncTools(p *parsedOptions, only map[string]bool) error {
+	allTools, err := readTools(p)
+	if err != nil {
+		return err
+	}
+	tools := allTools
+	if only != nil {
+		tools = []*tool{}
+		for _, t := range allTools {
+			if only[t.id()] {
+				tools = append(tools, t)
+			}
+		}
+	}
+	parseFile, err := readTrackingModfile(p)
+	if err != nil {
+		return err
+	}
+	goVer, err := goVersion(p)
 	if err != nil {
 		return err
 	}
 
-	for _, t := range tools {
-		args := []string{"install", "-v"}
-		if t.BuildFlags != "" {
-			split, err := shellquote.Split(t.BuildFlags)
-			if err != nil {
-				return fmt.Errorf("error splitting args: %w", err)
+	allArtifacts, err := readArtifacts(p)
+	if err != nil {
+		return err
+	}
+	artifacts := allArtifacts
+	if only != nil {
+		artifacts = []*Artifact{}
+		for _, a := range allArtifacts {
+			if only[a.Name] {
+				artifacts = append(artifacts, a)
 			}
-			args = append(args, split...)
 		}
-		args = append(args, t.Pkg)
-		goinstall := exec.Command(p.goBinary, args...)
-		goinstall.Stdout = newLogWriter(p.logger)
-		goinstall.Stderr = newLogWriter(p.logger)
-		absToolsdir, err := filepath.Abs(p.toolsdirName)
+	}
+
+	errs := make([]error, len(tools))
+	installed := make([]bool, len(tools))
+	parallel(len(tools), p, func(i int, logger Logger) {
+		s, err := newStamp(tools[i], parseFile, goVer, p)
 		if err != nil {
-			return fmt.Errorf("error finding absolute path to toolsdir %s: %w", p.toolsdirName, err)
+			errs[i] = err
+			return
 		}
-		goinstall.Env = append(os.Environ(), "GOBIN="+absToolsdir)
-		p.logger.Printf("running \"%s\", with GOBIN=%s", shellquote.Join(goinstall.Args...), absToolsdir)
-		if err := goinstall.Run(); err != nil {
-			return fmt.Errorf("error calling go install: %w", err)
+		installed[i], errs[i] = syncTool(tools[i], s, p, logger)
+	})
+
+	artifactErrs := make([]error, len(artifacts))
+	parallel(len(artifacts), p, func(i int, logger Logger) {
+		artifactErrs[i] = syncArtifact(artifacts[i], p, logger)
+	})
+	errs = append(errs, artifactErrs...)
+
+	syncErr := &SyncError{}
+	for _, err := range errs {
+		if err != nil {
+			syncErr.Errors = append(syncErr.Errors, err)
+		}
+	}
+
+	// The lockfile is written even if some tools failed, and a failure to write it must not hide why they failed
+	installedPkgs := map[string]bool{}
+	for i, t := range tools {
+		installedPkgs[t.id()] = installed[i]
+	}
+	lockErr := writeLockfile(allTools, installedPkgs, p)
+
+	switch {
+	case len(syncErr.Errors) > 0 && lockErr != nil:
+		return errors.Join(syncErr, lockErr)
+	case len(syncErr.Errors) > 0:
+		return syncErr
+	default:
+		return lockErr
+	}
+}
+
+// parallel calls fn with every index up to n, running at most p.jobs calls at once
+func parallel(n int, p *parsedOptions, fn func(i int, logger Logger)) {
+	sem := make(chan struct{}, p.jobs)
+	logMutex := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			// Output is buffered per call so that parallel installs don't interleave in the log
+			logger := &bufferedLogger{}
+			fn(i, logger)
+
+			logMutex.Lock()
+			defer logMutex.Unlock()
+			logger.flush(p.logger)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// syncTool installs a tool, unless the stamp shows it has already been built with the same inputs.  Returns whether the tool was installed.
+func syncTool(t *tool, s *stamp, p *parsedOptions, logger Logger) (bool, error) {
+	if !p.force && s.isCurrent(t, p) {
+		logger.Printf("%s is up to date at %s, skipping", t.Pkg, s.Version)
+		return false, nil
+	}
+	if p.force {
+		// go install leaves a binary alone if its build ID looks current, so it must be removed to guarantee a rebuild
+		if err := os.Remove(binaryPath(t, p)); err != nil && !os.IsNotExist(err) {
+			return false, fmt.Errorf("error removing %s for rebuild: %w", t.Pkg, err)
 		}
 	}
+	if err := installTool(t, p, logger); err != nil {
+		return false, err
+	}
+	return true, s.write(p)
+}
 
-	return nil
+func installTool(t *tool, p *parsedOptions, logger Logger) error {
+	args := []string{"install", "-v"}
+	flags, err := buildFlagArgs(t.BuildFlags)
+	if err != nil {
+		return err
+	}
+	args = append(args, flags...)
+	switch p.mode {
+	case GlobalMode:
+		args = append(args, t.Pkg+"@"+t.Version)
+	default:
+		if isolated(t, p) {
+			if _, err := preparePerToolModule(t, t.Version, p, logger); err != nil {
+				return err
+			}
+		}
+		args = append(args, t.Pkg)
+	}
+	goinstall := goCommand(p, args...)
+	goinstall.Dir, err = toolModuleDir(t, p)
+	if err != nil {
+		return err
+	}
+	// One writer is shared, so that exec copies both streams in the same goroutine
+	w := newLogWriter(logger)
+	goinstall.Stdout = w
+	goinstall.Stderr = w
+	gobin, err := installGobin(t, p)
+	if err != nil {
+		return err
+	}
+	if binaryName(t) != binaryName(&tool{Pkg: t.Pkg}) {
+		defer os.RemoveAll(gobin)
+	}
+	goinstall.Env = append(os.Environ(), "GOBIN="+gobin)
+	logger.Printf("running \"%s\", with GOBIN=%s", shellquote.Join(goinstall.Args...), gobin)
+	if err := goinstall.Run(); err != nil {
+		return fmt.Errorf("error calling go install for %s: %w", t.Pkg, err)
+	}
+	return finishInstall(t, gobin, p)
 }
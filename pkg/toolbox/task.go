@@ -0,0 +1,157 @@
+package toolbox
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/kballard/go-shellquote"
+)
+
+// Task is a named list of commands, which are run with the vendored tools available, the same way Do runs them
+type Task struct {
+	// Name is the name the task is run by, which is case insensitive
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Run holds the command lines to run, in order.  Each line is split into arguments like a shell would, but no other shell syntax, such as variables or pipes, is supported.  Run "sh -c '...'" for that.
+	Run []string `json:"run" yaml:"run"`
+	// Deps are the names of tasks that must be run before this one.  Each task is only run once, no matter how many tasks depend on it.
+	Deps []string `json:"deps,omitempty" yaml:"deps,omitempty"`
+	// Env holds environment variables that are set for every command in the task
+	Env map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+}
+
+// ListTasks returns every task given with TasksOption, sorted by name
+func ListTasks(options ...Option) ([]*Task, error) {
+	p := applyOptions(options...)
+	byName, err := validateTasks(p.tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make([]*Task, 0, len(byName))
+	for _, t := range byName {
+		tasks = append(tasks, t)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return strings.ToLower(tasks[i].Name) < strings.ToLower(tasks[j].Name) })
+	return tasks, nil
+}
+
+// RunTasks runs the named tasks in order, each after the tasks that it depends on.  No task is run more than once.  Commands are run in the base directory, and the first command to fail stops everything.
+func RunTasks(names []string, options ...Option) error {
+	p, err := parseOptions(options...)
+	if err != nil {
+		return fmt.Errorf("error parsing options: %w", err)
+	}
+	byName, err := validateTasks(p.tasks)
+	if err != nil {
+		return err
+	}
+
+	order, err := taskOrder(names, byName)
+	if err != nil {
+		return err
+	}
+	for _, t := range order {
+		if err := runTask(t, p, options); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateTasks checks that tasks can be run, and returns them by their lower case names
+func validateTasks(tasks []*Task) (map[string]*Task, error) {
+	byName := map[string]*Task{}
+	for _, t := range tasks {
+		name := strings.ToLower(t.Name)
+		if other, ok := byName[name]; ok {
+			return nil, fmt.Errorf("tasks %s and %s have the same name, as task names are case insensitive", other.Name, t.Name)
+		}
+		byName[name] = t
+	}
+	for _, t := range tasks {
+		if len(t.Run) == 0 && len(t.Deps) == 0 {
+			return nil, fmt.Errorf("task %s has nothing to run", t.Name)
+		}
+		for _, dep := range t.Deps {
+			if _, ok := byName[strings.ToLower(dep)]; !ok {
+				return nil, fmt.Errorf("task %s depends on unknown task %s", t.Name, dep)
+			}
+		}
+		for envName := range t.Env {
+			if !validEnvName.MatchString(envName) {
+				return nil, fmt.Errorf("invalid environment variable name %s in task %s", envName, t.Name)
+			}
+		}
+	}
+	return byName, nil
+}
+
+// taskOrder returns the named tasks and everything they depend on, with every task after its dependencies.  Tasks are looked up by their lower case names, as returned by validateTasks.
+func taskOrder(names []string, tasks map[string]*Task) ([]*Task, error) {
+	order := []*Task{}
+	done := map[string]bool{}
+	visiting := map[string]bool{}
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		name = strings.ToLower(name)
+		if done[name] {
+			return nil
+		}
+		path = append(path, name)
+		if visiting[name] {
+			return fmt.Errorf("tasks depend on each other in a cycle: %s", strings.Join(path, " -> "))
+		}
+		t, ok := tasks[name]
+		if !ok {
+			return fmt.Errorf("unknown task %s", name)
+		}
+		visiting[name] = true
+		for _, dep := range t.Deps {
+			if err := visit(dep, path); err != nil {
+				return err
+			}
+		}
+		visiting[name] = false
+		done[name] = true
+		order = append(order, t)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+func runTask(t *Task, p *parsedOptions, options []Option) error {
+	for _, line := range t.Run {
+		args, err := shellquote.Split(line)
+		if err != nil {
+			return fmt.Errorf("error parsing command \"%s\" of task %s: %w", line, t.Name, err)
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		cmd, err := CommandOpts(args[0], args[1:], options...)
+		if err != nil {
+			return err
+		}
+		for name, value := range t.Env {
+			cmd.Env = append(cmd.Env, name+"="+value)
+		}
+		cmd.Dir = p.basedirName
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		p.logger.Printf("running task %s: %s", t.Name, shellquote.Join(args...))
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("error running task %s: %w", t.Name, err)
+		}
+	}
+	return nil
+}
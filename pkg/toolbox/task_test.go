@@ -0,0 +1,146 @@
+package toolbox
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTaskOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		tasks    []*Task
+		run      []string
+		expected []string
+		err      string
+	}{
+		{
+			name: "dependencies first",
+			tasks: []*Task{
+				{Name: "build", Run: []string{"go build"}, Deps: []string{"generate", "lint"}},
+				{Name: "generate", Run: []string{"go generate"}},
+				{Name: "lint", Run: []string{"golint"}, Deps: []string{"generate"}},
+			},
+			run:      []string{"build"},
+			expected: []string{"generate", "lint", "build"},
+		},
+		{
+			name: "each task once",
+			tasks: []*Task{
+				{Name: "build", Run: []string{"go build"}, Deps: []string{"generate"}},
+				{Name: "test", Run: []string{"go test"}, Deps: []string{"generate"}},
+				{Name: "generate", Run: []string{"go generate"}},
+			},
+			run:      []string{"build", "test", "build"},
+			expected: []string{"generate", "build", "test"},
+		},
+		{
+			name: "case insensitive",
+			tasks: []*Task{
+				{Name: "Build", Run: []string{"go build"}, Deps: []string{"GENERATE"}},
+				{Name: "generate", Run: []string{"go generate"}},
+			},
+			run:      []string{"build"},
+			expected: []string{"generate", "Build"},
+		},
+		{
+			name: "cycle",
+			tasks: []*Task{
+				{Name: "a", Run: []string{"true"}, Deps: []string{"b"}},
+				{Name: "b", Run: []string{"true"}, Deps: []string{"c"}},
+				{Name: "c", Run: []string{"true"}, Deps: []string{"a"}},
+			},
+			run: []string{"a"},
+			err: "cycle: a -> b -> c -> a",
+		},
+		{
+			name:  "unknown task",
+			tasks: []*Task{{Name: "build", Run: []string{"go build"}}},
+			run:   []string{"deploy"},
+			err:   "unknown task deploy",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			byName, err := validateTasks(test.tasks)
+			if err != nil {
+				t.Fatalf("error validating tasks: %v", err)
+			}
+			order, err := taskOrder(test.run, byName)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error ordering tasks: %v", err)
+			}
+			names := make([]string, len(order))
+			for i, task := range order {
+				names[i] = task.Name
+			}
+			if !reflect.DeepEqual(names, test.expected) {
+				t.Errorf("got order %v, expected %v", names, test.expected)
+			}
+		})
+	}
+}
+
+func TestValidateTasks(t *testing.T) {
+	tests := []struct {
+		name  string
+		tasks []*Task
+		err   string
+	}{
+		{
+			name: "same name in another case",
+			tasks: []*Task{
+				{Name: "build", Run: []string{"go build"}},
+				{Name: "Build", Run: []string{"go build ./..."}},
+			},
+			err: "tasks build and Build have the same name",
+		},
+		{
+			name:  "unknown dependency",
+			tasks: []*Task{{Name: "build", Run: []string{"go build"}, Deps: []string{"generate"}}},
+			err:   "task build depends on unknown task generate",
+		},
+		{
+			name:  "nothing to run",
+			tasks: []*Task{{Name: "build"}},
+			err:   "task build has nothing to run",
+		},
+		{
+			name:  "invalid environment variable",
+			tasks: []*Task{{Name: "build", Run: []string{"go build"}, Env: map[string]string{"CGO-ENABLED": "0"}}},
+			err:   "invalid environment variable name CGO-ENABLED",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := validateTasks(test.tasks)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestListTasks(t *testing.T) {
+	tasks, err := ListTasks(TasksOption([]*Task{
+		{Name: "test", Run: []string{"go test"}},
+		{Name: "Build", Run: []string{"go build"}},
+		{Name: "generate", Run: []string{"go generate"}},
+	}))
+	if err != nil {
+		t.Fatalf("error listing tasks: %v", err)
+	}
+	names := make([]string, len(tasks))
+	for i, task := range tasks {
+		names[i] = task.Name
+	}
+	if expected := []string{"Build", "generate", "test"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("got tasks %v, expected %v", names, expected)
+	}
+}
@@ -0,0 +1,168 @@
+package toolbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// Backend determines where toolbox stores the list of tools it tracks
+type Backend int
+
+const (
+	// ToolsfileBackend stores tools as blank imports in the toolsfile.  This is the default.
+	ToolsfileBackend Backend = iota
+	// GomodBackend stores tools as "tool" directives in go.mod, as supported by go 1.24 and newer.
+	GomodBackend
+)
+
+var backendNames = map[Backend]string{
+	ToolsfileBackend: "toolsfile",
+	GomodBackend:     "gomod",
+}
+
+func (b Backend) String() string {
+	if name, ok := backendNames[b]; ok {
+		return name
+	}
+	return fmt.Sprintf("Backend(%d)", int(b))
+}
+
+// ParseBackend converts the name of a backend, as returned by Backend.String, back into a Backend
+func ParseBackend(name string) (Backend, error) {
+	for backend, backendName := range backendNames {
+		if backendName == name {
+			return backend, nil
+		}
+	}
+	return ToolsfileBackend, fmt.Errorf("unknown backend \"%s\"", name)
+}
+
+// toolDirectiveGoVersion is the first version of go to understand tool directives
+const toolDirectiveGoVersion = "1.24"
+
+// Migrate moves the list of tools into the given backend, out of the other one
+func Migrate(to Backend, options ...Option) error {
+	p, err := parseOptions(options...)
+	if err != nil {
+		return fmt.Errorf("error parsing options: %w", err)
+	}
+	if p.mode != SharedMode {
+		return fmt.Errorf("tools can only be migrated in %s mode", SharedMode)
+	}
+
+	switch to {
+	case GomodBackend:
+		tools, err := readToolsfile(p)
+		if err != nil {
+			return err
+		}
+		if err := writeGomodTools(tools, p); err != nil {
+			return err
+		}
+		p.logger.Printf("removing toolsfile %s", p.toolsfileName)
+		if err := os.Remove(p.toolsfileName); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing toolsfile %s: %w", p.toolsfileName, err)
+		}
+	case ToolsfileBackend:
+		tools, err := readGomodTools(p)
+		if err != nil {
+			return err
+		}
+		if err := writeToolsfile(tools, p); err != nil {
+			return err
+		}
+		if err := writeGomodTools(nil, p); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown backend %s", to)
+	}
+	return nil
+}
+
+func readGomodTools(p *parsedOptions) ([]*tool, error) {
+	if _, err := os.Stat(filepath.Join(p.moduleDir, "go.mod")); os.IsNotExist(err) {
+		return nil, nil
+	}
+	parseFile, err := readModfile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	tools := []*tool{}
+	for _, directive := range parseFile.Tool {
+		t := &tool{}
+		for _, comment := range directive.Syntax.Comments.Suffix {
+			text := strings.TrimSpace(strings.TrimPrefix(comment.Token, "//"))
+			if !strings.HasPrefix(text, "{") {
+				continue
+			}
+			if err := json.Unmarshal([]byte(text), t); err != nil {
+				return nil, fmt.Errorf("error parsing tool comment as json: %w", err)
+			}
+		}
+		t.Pkg = directive.Path
+		tools = append(tools, t)
+	}
+	return tools, nil
+}
+
+// writeGomodTools replaces the tool directives in go.mod with the given tools.  Build flags are stored as a json comment at the end of each directive.
+func writeGomodTools(tools []*tool, p *parsedOptions) error {
+	if err := ensureModule(p); err != nil {
+		return err
+	}
+	parseFile, err := readModfile(p)
+	if err != nil {
+		return err
+	}
+	if len(tools) > 0 && (parseFile.Go == nil || semver.Compare("v"+parseFile.Go.Version, "v"+toolDirectiveGoVersion) < 0) {
+		return fmt.Errorf("tool directives require go %s or newer in go.mod, run \"go mod edit -go=%s\" first", toolDirectiveGoVersion, toolDirectiveGoVersion)
+	}
+
+	wanted := map[string]bool{}
+	for _, t := range tools {
+		wanted[t.Pkg] = true
+	}
+	for _, directive := range parseFile.Tool {
+		if directive.Path != "" && !wanted[directive.Path] {
+			if err := parseFile.DropTool(directive.Path); err != nil {
+				return fmt.Errorf("error removing tool directive for %s: %w", directive.Path, err)
+			}
+		}
+	}
+
+	for _, t := range tools {
+		if err := parseFile.AddTool(t.Pkg); err != nil {
+			return fmt.Errorf("error adding tool directive for %s: %w", t.Pkg, err)
+		}
+		j, err := json.Marshal(t)
+		if err != nil {
+			return fmt.Errorf("error generating tool directive comment: %w", err)
+		}
+		for _, directive := range parseFile.Tool {
+			if directive.Path != t.Pkg {
+				continue
+			}
+			directive.Syntax.Comments.Suffix = nil
+			if string(j) != "{}" {
+				directive.Syntax.Comments.Suffix = []modfile.Comment{{Token: "// " + string(j), Suffix: true}}
+			}
+		}
+	}
+	parseFile.Cleanup()
+
+	filename := filepath.Join(p.moduleDir, "go.mod")
+	p.logger.Printf("writing tool directives to %s", filename)
+	if err := ioutil.WriteFile(filename, modfile.Format(parseFile.Syntax), 0666); err != nil {
+		return fmt.Errorf("error writing modfile %s: %w", filename, err)
+	}
+	return nil
+}
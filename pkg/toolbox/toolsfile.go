@@ -13,19 +13,33 @@ import (
 	"github.com/kballard/go-shellquote"
 )
 
-var toolsTemplate = template.Must(template.New("tools_template").Parse(`// +build tools
+var toolsTemplate = template.Must(template.New("tools_template").Parse(`// +build {{ .BuildTag }}
 
 // This file is generated and managed by toolbox.  Manually edit at your own peril.
 package toolbox
 
 import (
-	{{range .}}
+	{{range .Tools}}
 	_ "{{ .Pkg }}" {{if ne .Comment "{}" }} //{{ .Comment }} {{end}}{{end}}
 )`))
 
 type tool struct {
 	Pkg        string `json:"-"`
+	Module     string `json:"module,omitempty"`
+	Version    string `json:"version,omitempty"`
 	BuildFlags string `json:"build_flags,omitempty"`
+	Replace    string `json:"replace,omitempty"`
+	Name       string `json:"name,omitempty"`
+	Alias      string `json:"alias,omitempty"`
+	// Env holds environment variables that are set whenever tools are run
+	Env map[string]string `json:"env,omitempty"`
+	// Aliases holds side-by-side versions of the tool while it's being read or written, and is otherwise empty
+	Aliases []*tool `json:"aliases,omitempty"`
+}
+
+type toolsfileTemplate struct {
+	BuildTag string
+	Tools    []*toolTemplate
 }
 
 type toolTemplate struct {
@@ -33,7 +47,34 @@ type toolTemplate struct {
 	Comment string
 }
 
+// readTools reads the list of tracked tools from whichever backend is in use
 func readTools(p *parsedOptions) ([]*tool, error) {
+	var tools []*tool
+	var err error
+	if p.backend == GomodBackend {
+		tools, err = readGomodTools(p)
+	} else {
+		tools, err = readToolsfile(p)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return flattenAliases(tools), nil
+}
+
+// writeTools stores the list of tracked tools in whichever backend is in use
+func writeTools(tools []*tool, p *parsedOptions) error {
+	nested, err := nestAliases(tools)
+	if err != nil {
+		return err
+	}
+	if p.backend == GomodBackend {
+		return writeGomodTools(nested, p)
+	}
+	return writeToolsfile(nested, p)
+}
+
+func readToolsfile(p *parsedOptions) ([]*tool, error) {
 	if _, err := os.Stat(p.toolsfileName); os.IsNotExist(err) {
 		return nil, nil
 	}
@@ -57,7 +98,7 @@ func readTools(p *parsedOptions) ([]*tool, error) {
 	return tools, nil
 }
 
-func writeTools(tools []*tool, p *parsedOptions) error {
+func writeToolsfile(tools []*tool, p *parsedOptions) error {
 	file, err := os.OpenFile(p.toolsfileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
 	if err != nil {
 		return fmt.Errorf("error opening tools file %s: %w", p.toolsfileName, err)
@@ -77,7 +118,12 @@ func writeTools(tools []*tool, p *parsedOptions) error {
 	}
 
 	p.logger.Printf("writing toolsfile %s", p.toolsfileName)
-	if err := toolsTemplate.Execute(file, toolTemplates); err != nil {
+	// Outside of shared mode, the toolsfile must be ignored by the module it lives in, so that tools don't end up in its go.mod
+	buildTag := "tools"
+	if p.mode != SharedMode {
+		buildTag = "ignore"
+	}
+	if err := toolsTemplate.Execute(file, &toolsfileTemplate{BuildTag: buildTag, Tools: toolTemplates}); err != nil {
 		return fmt.Errorf("error writing data to toolsfile %s: %w", p.toolsfileName, err)
 	}
 	if err := file.Close(); err != nil {
@@ -0,0 +1,84 @@
+package toolbox
+
+import (
+	"fmt"
+)
+
+// UpgradePolicy limits how far Upgrade is allowed to move a tool's version
+type UpgradePolicy int
+
+const (
+	// UpgradeMinor upgrades tools to the latest version with the same major version.  This is the default.
+	UpgradeMinor UpgradePolicy = iota
+	// UpgradePatch upgrades tools to the latest version with the same major and minor version.
+	UpgradePatch
+)
+
+// UpgradedTool records the version change of a single tool after an upgrade
+type UpgradedTool struct {
+	Package    string `json:"package"`
+	OldVersion string `json:"old_version"`
+	NewVersion string `json:"new_version"`
+}
+
+// Upgrade moves the given tools to the newest version allowed by policy.  If no packages are given, all tools are upgraded.  Build flags previously stored for each tool are preserved.
+func Upgrade(packages []string, policy UpgradePolicy, options ...Option) ([]*UpgradedTool, error) {
+	p, err := parseOptions(options...)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing options: %w", err)
+	}
+
+	tools, err := readTools(p)
+	if err != nil {
+		return nil, err
+	}
+	buildFlags := map[string]string{}
+	for _, t := range tools {
+		buildFlags[t.id()] = t.BuildFlags
+	}
+
+	available, err := outdated(p)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := available
+	if len(packages) > 0 {
+		byPackage := map[string]*OutdatedTool{}
+		for _, o := range available {
+			byPackage[o.Package] = o
+		}
+		selected = make([]*OutdatedTool, len(packages))
+		for i, pkg := range packages {
+			o, ok := byPackage[pkg]
+			if !ok {
+				return nil, fmt.Errorf("package %s is not a tracked tool", pkg)
+			}
+			selected[i] = o
+		}
+	}
+
+	upgraded := []*UpgradedTool{}
+	for _, o := range selected {
+		newVersion := o.LatestMinor
+		// Side-by-side versions exist to pin an older release line, so they only receive patches
+		if _, alias := splitAlias(o.Package); policy == UpgradePatch || alias != "" {
+			newVersion = o.LatestPatch
+		}
+		if newVersion == o.Version {
+			p.logger.Printf("%s is already at %s", o.Package, o.Version)
+			continue
+		}
+
+		addOptions := append(append([]Option{}, options...), BuildFlagsOption(buildFlags[o.Package]))
+		if err := AddVer(o.Package, newVersion, addOptions...); err != nil {
+			return upgraded, fmt.Errorf("error upgrading %s: %w", o.Package, err)
+		}
+		upgraded = append(upgraded, &UpgradedTool{
+			Package:    o.Package,
+			OldVersion: o.Version,
+			NewVersion: newVersion,
+		})
+	}
+	return upgraded, nil
+}
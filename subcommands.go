@@ -1,83 +1,732 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 
 	"github.com/Houndie/toolbox/pkg/toolbox"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var doCommand = &cobra.Command{
 	Use:   "do <command>",
 	Short: "Run a command using the vendored version of tools",
-	Long:  "Edits the PATH to reflect the tool vendor directly, and runs the given command.",
+	Long:  "Edits the PATH to reflect the tool vendor directly, and runs the given command.  If the command is a tracked tool that is missing or out of date, it's installed first.",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		err := toolbox.DoOpts(args, makeOptions()...)
-		if err == nil {
-			return nil
+		options, err := makeOptions()
+		if err != nil {
+			return err
 		}
-		return err
+		noSync, err := cmd.Flags().GetBool("no-sync")
+		if err != nil {
+			return err
+		}
+		options = append(options, toolbox.AutoSyncOption(!noSync))
+		return toolbox.DoOpts(args, options...)
 	},
 }
 
 var addCommand = &cobra.Command{
 	Use:   "add <dependency> [version]",
 	Short: "Add a new dependency",
-	Long:  "Adds dependency to the list of dependencies managed by toolbox.  If a version is provided, adds that version as well.",
+	Long:  "Adds dependency to the list of dependencies managed by toolbox.  If a version is provided, adds that version as well.  The dependency may be a pattern like \"golang.org/x/tools/cmd/...\", which adds every matching command in the module.",
 	Args:  cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		options, err := makeOptions()
+		if err != nil {
+			return err
+		}
+		replace, err := cmd.Flags().GetString("replace")
+		if err != nil {
+			return err
+		}
+		if replace != "" {
+			options = append(options, toolbox.ReplaceOption(replace))
+		}
+		noReplace, err := cmd.Flags().GetBool("no-replace")
+		if err != nil {
+			return err
+		}
+		if noReplace {
+			options = append(options, toolbox.DropReplaceOption(true))
+		}
+		name, err := cmd.Flags().GetString("name")
+		if err != nil {
+			return err
+		}
+		if name != "" {
+			options = append(options, toolbox.NameOption(name))
+		}
+		env, err := cmd.Flags().GetStringToString("env")
+		if err != nil {
+			return err
+		}
+		if len(env) > 0 {
+			options = append(options, toolbox.EnvOption(env))
+		}
 		if len(args) > 1 {
-			return toolbox.AddVer(args[0], args[1], makeOptions()...)
+			return toolbox.AddVer(args[0], args[1], options...)
+		}
+		return toolbox.Add(args[0], options...)
+	},
+}
+
+var addArtifactCommand = &cobra.Command{
+	Use:   "add-artifact <name> <version> <url>",
+	Short: "Add a prebuilt tool downloaded from a release",
+	Long:  "Adds a tool that isn't built with go, such as protoc, by downloading a release artifact.  The url may use {{.OS}}, {{.Arch}}, and {{.Version}}, which are filled in for the current platform.  The download is checked against the expected hash, which is recorded and checked on every future sync.",
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		archive, err := cmd.Flags().GetString("archive")
+		if err != nil {
+			return err
+		}
+		if archive == "" {
+			archive = archiveFromURL(args[2])
+		}
+		artifactPath, err := cmd.Flags().GetString("path")
+		if err != nil {
+			return err
+		}
+		osNames, err := cmd.Flags().GetStringToString("os-name")
+		if err != nil {
+			return err
+		}
+		archNames, err := cmd.Flags().GetStringToString("arch-name")
+		if err != nil {
+			return err
+		}
+		sum, err := cmd.Flags().GetString("sha256")
+		if err != nil {
+			return err
+		}
+		checksums, err := cmd.Flags().GetString("checksums")
+		if err != nil {
+			return err
 		}
-		return toolbox.Add(args[0], makeOptions()...)
+		signature, err := cmd.Flags().GetString("signature")
+		if err != nil {
+			return err
+		}
+		publicKey, err := cmd.Flags().GetString("public-key")
+		if err != nil {
+			return err
+		}
+
+		artifact := &toolbox.Artifact{
+			Name:      args[0],
+			Version:   args[1],
+			URL:       args[2],
+			Format:    toolbox.ArchiveFormat(archive),
+			Path:      artifactPath,
+			Checksums: checksums,
+			Signature: signature,
+			PublicKey: publicKey,
+		}
+		if len(osNames) > 0 {
+			artifact.OSNames = osNames
+		}
+		if len(archNames) > 0 {
+			artifact.ArchNames = archNames
+		}
+		if sum != "" {
+			artifact.SHA256 = map[string]string{runtime.GOOS + "/" + runtime.GOARCH: sum}
+		}
+
+		trust, err := cmd.Flags().GetBool("trust-download")
+		if err != nil {
+			return err
+		}
+
+		options, err := makeOptions()
+		if err != nil {
+			return err
+		}
+		if trust {
+			options = append(options, toolbox.TrustDownloadOption(trust))
+		}
+		return toolbox.AddArtifact(artifact, options...)
 	},
 }
 
+// archiveFromURL guesses the archive format of an artifact from its file extension
+func archiveFromURL(url string) string {
+	switch {
+	case strings.HasSuffix(url, ".tar.gz"), strings.HasSuffix(url, ".tgz"):
+		return string(toolbox.TarGzArchive)
+	case strings.HasSuffix(url, ".zip"):
+		return string(toolbox.ZipArchive)
+	default:
+		return string(toolbox.RawArchive)
+	}
+}
+
 var removeCommand = &cobra.Command{
 	Use:   "remove <dependency>",
 	Short: "Remove a dependency",
-	Long:  "Removes a dependency, and attempts to remove the executable of the same name as well.",
+	Long:  "Removes a dependency, and attempts to remove the executable of the same name as well.  The dependency may be a pattern like \"golang.org/x/tools/...\", which removes every matching dependency.",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return toolbox.Remove(args[0], makeOptions()...)
+		options, err := makeOptions()
+		if err != nil {
+			return err
+		}
+		return toolbox.Remove(args[0], options...)
 	},
 }
 
 var syncCommand = &cobra.Command{
 	Use:   "sync",
 	Short: "Make sure all dependencies are at the correct version",
-	Long:  "Uses go install to install all of our dependencies.  Installs from module cache if they are found, from the internet if not.",
+	Long:  "Uses go install to install all of our dependencies.  Installs from module cache if they are found, from the internet if not.  Tools that were already built with the same version, build flags, and go version are skipped.",
 	Args:  cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return toolbox.Sync(makeOptions()...)
+		options, err := makeOptions()
+		if err != nil {
+			return err
+		}
+		return toolbox.Sync(options...)
 	},
 }
 
 var listCommand = &cobra.Command{
 	Use:   "list",
 	Short: "Lists all tool dependencies and their information",
-	Long:  "Parses tools.go and go.mod, and prints the information in easy to parse json",
+	Long:  "Parses tools.go and go.mod, and prints the information as a table, or in an easy to parse format such as json",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		modules, err := cmd.Flags().GetBool("modules")
+		if err != nil {
+			return err
+		}
+		format, err := cmd.Flags().GetString(formatFlag)
+		if err != nil {
+			return err
+		}
+		if format == "" {
+			format = jsonFormat
+			if isTerminal(os.Stdout) {
+				format = tableFormat
+			}
+		}
+		if format != tableFormat && format != jsonFormat && format != yamlFormat && format != csvFormat && !isTemplateFormat(format) {
+			return unknownFormatError(format)
+		}
+
+		options, err := makeOptions()
+		if err != nil {
+			return err
+		}
+
+		var tools []*toolbox.Tool
+		var toolModules []*toolbox.ToolModule
+		if modules {
+			toolModules, err = toolbox.ListModules(options...)
+			for _, m := range toolModules {
+				tools = append(tools, m.Tools...)
+			}
+		} else {
+			tools, err = toolbox.List(options...)
+		}
+		if err != nil {
+			return err
+		}
+
+		headers := []string{"PACKAGE", "MODULE", "VERSION", "INSTALLED", "BUILD FLAGS", "INDIRECT", "REPLACE"}
+		rows := make([][]string, len(tools))
+		for i, t := range tools {
+			replace := ""
+			if t.Replace != nil {
+				replace = strings.TrimSpace(t.Replace.Path + " " + t.Replace.Version)
+			}
+			installed := "missing"
+			if t.Installed {
+				installed = t.InstalledVersion
+				if installed == "" {
+					installed = "unknown"
+				}
+			}
+			mod := t.Module
+			if t.NotPrepared {
+				mod = "not prepared"
+			}
+			rows[i] = []string{t.Package, mod, t.Version, installed, t.BuildFlags, strconv.FormatBool(t.Indirect), replace}
+		}
+
+		switch format {
+		case tableFormat:
+			return printTable(os.Stdout, headers, rows)
+		case csvFormat:
+			return printCSV(os.Stdout, headers, rows)
+		}
+
+		// Structured formats keep the module grouping
+		var v interface{} = tools
+		items := make([]interface{}, len(tools))
+		for i, t := range tools {
+			items[i] = t
+		}
+		if modules {
+			v = toolModules
+			items = make([]interface{}, len(toolModules))
+			for i, m := range toolModules {
+				items[i] = m
+			}
+		}
+		switch format {
+		case jsonFormat:
+			return printJSON(os.Stdout, v)
+		case yamlFormat:
+			return printYAML(os.Stdout, v)
+		}
+		return printTemplate(os.Stdout, format, items)
+	},
+}
+
+var outdatedCommand = &cobra.Command{
+	Use:   "outdated",
+	Short: "Lists newer versions of tool dependencies",
+	Long:  "Queries the module proxy for each tool, and prints the current version alongside the latest patch, minor, and major versions available",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := cmd.Flags().GetString(formatFlag)
+		if err != nil {
+			return err
+		}
+
+		options, err := makeOptions()
+		if err != nil {
+			return err
+		}
+		tools, err := toolbox.Outdated(options...)
+		if err != nil {
+			return err
+		}
+
+		switch format {
+		case jsonFormat:
+			return printJSON(os.Stdout, &tools)
+		case tableFormat:
+			rows := make([][]string, len(tools))
+			for i, t := range tools {
+				rows[i] = []string{t.Package, t.Version, t.LatestPatch, t.LatestMinor, t.LatestMajor}
+			}
+			return printTable(os.Stdout, []string{"PACKAGE", "CURRENT", "PATCH", "MINOR", "MAJOR"}, rows)
+		default:
+			return unknownFormatError(format)
+		}
+	},
+}
+
+var upgradeCommand = &cobra.Command{
+	Use:   "upgrade [dependency...]",
+	Short: "Upgrade dependencies to newer versions",
+	Long:  "Upgrades the given dependencies, or all dependencies with --all, to the newest available version.  By default tools may move to a new minor version; use --patch to only allow patch upgrades.  Stored build flags are kept.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, err := cmd.Flags().GetBool("all")
+		if err != nil {
+			return err
+		}
+		patch, err := cmd.Flags().GetBool("patch")
+		if err != nil {
+			return err
+		}
+		minor, err := cmd.Flags().GetBool("minor")
+		if err != nil {
+			return err
+		}
+
+		if all == (len(args) > 0) {
+			return errors.New("either provide dependencies to upgrade, or --all")
+		}
+		if patch && minor {
+			return errors.New("--patch and --minor cannot be used together")
+		}
+
+		policy := toolbox.UpgradeMinor
+		if patch {
+			policy = toolbox.UpgradePatch
+		}
+
+		options, err := makeOptions()
+		if err != nil {
+			return err
+		}
+		upgraded, err := toolbox.Upgrade(args, policy, options...)
+		for _, u := range upgraded {
+			fmt.Printf("%s %s -> %s\n", u.Package, u.OldVersion, u.NewVersion)
+		}
+		if err != nil {
+			return err
+		}
+		if len(upgraded) == 0 {
+			fmt.Println("all tools are up to date")
+		}
+		return nil
+	},
+}
+
+var verifyCommand = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify installed tools against the lockfile",
+	Long:  "Re-hashes every tool binary, and compares it to the checksums recorded in toolbox.lock.  Exits with an error if any tool is missing, modified, or out of date.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := cmd.Flags().GetString(formatFlag)
+		if err != nil {
+			return err
+		}
+
+		options, err := makeOptions()
+		if err != nil {
+			return err
+		}
+		results, err := toolbox.Verify(options...)
+		if err != nil {
+			return err
+		}
+
+		switch format {
+		case jsonFormat:
+			if err := printJSON(os.Stdout, &results); err != nil {
+				return err
+			}
+		case tableFormat:
+			rows := make([][]string, len(results))
+			for i, r := range results {
+				rows[i] = []string{r.Package, string(r.Status), r.Expected, r.Actual}
+			}
+			if err := printTable(os.Stdout, []string{"PACKAGE", "STATUS", "EXPECTED", "ACTUAL"}, rows); err != nil {
+				return err
+			}
+		default:
+			return unknownFormatError(format)
+		}
+
+		failed := 0
+		for _, r := range results {
+			if r.Status != toolbox.VerifyOK {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d tool(s) failed verification", failed)
+		}
+		return nil
+	},
+}
+
+var doctorCommand = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose problems with the tool setup",
+	Long:  "Checks that go and goimports can be found, that the project is a go module, that the tools file is build-tagged correctly, that every tool has a version and a binary, and that the tools directory is ignored by git.  Prints suggested fixes for any problems, and exits with an error if any check fails.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := cmd.Flags().GetString(formatFlag)
+		if err != nil {
+			return err
+		}
+
+		options, err := makeOptions()
+		if err != nil {
+			return err
+		}
+		checks, err := toolbox.Doctor(options...)
+		if err != nil {
+			return err
+		}
+
+		switch format {
+		case jsonFormat:
+			if err := printJSON(os.Stdout, &checks); err != nil {
+				return err
+			}
+		case tableFormat:
+			for _, c := range checks {
+				fmt.Printf("[%s] %s: %s\n", c.Status, c.Name, c.Message)
+				if c.Fix != "" {
+					fmt.Printf("       fix: %s\n", c.Fix)
+				}
+			}
+		default:
+			return unknownFormatError(format)
+		}
+
+		failed := 0
+		for _, c := range checks {
+			if c.Status == toolbox.CheckFail {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d check(s) failed", failed)
+		}
+		return nil
+	},
+}
+
+var shimsCommand = &cobra.Command{
+	Use:   "shims",
+	Short: "Write wrapper scripts that run vendored tools",
+	Long:  "Writes a small script for every tool into a directory, which runs the vendored tool through \"toolbox do\", installing it first if it's missing or out of date.  Put the directory on your PATH, or call the scripts directly, and tools are always run at the tracked version.  Scripts for tools that are no longer tracked are removed.",
 	Args:  cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		tools, err := toolbox.List(makeOptions()...)
+		dir, err := cmd.Flags().GetString("dir")
 		if err != nil {
 			return err
 		}
+		options, err := makeOptions()
+		if err != nil {
+			return err
+		}
+		executable, err := cmd.Flags().GetString("toolbox")
+		if err != nil {
+			return err
+		}
+		if executable != "" {
+			// Relative paths would otherwise be resolved against whichever directory the shim is run from
+			if strings.ContainsAny(executable, `/\`) {
+				executable, err = filepath.Abs(executable)
+				if err != nil {
+					return fmt.Errorf("error finding absolute path to %s: %w", executable, err)
+				}
+			}
+			options = append(options, toolbox.ShimCommandOption(executable))
+		}
+		// Without it, shims run from another directory wouldn't find the configuration file
+		if configfile := viper.ConfigFileUsed(); configfile != "" {
+			if _, err := os.Stat(configfile); err == nil {
+				options = append(options, toolbox.ShimConfigfileOption(configfile))
+			}
+		}
+		return toolbox.Shims(dir, options...)
+	},
+}
 
-		j, err := json.MarshalIndent(&tools, "", "\t")
+var envCommand = &cobra.Command{
+	Use:   "env",
+	Short: "Print a script that activates the vendored tools in your shell",
+	Long:  "Prints commands that put the tools directory on your PATH, set GOBIN, and set any environment variables that tools declare, along with a \"toolbox_deactivate\" function that undoes them.  Run it with \"eval \"$(toolbox env)\"\", or \"toolbox env --shell fish | source\" in fish.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		shellName, err := cmd.Flags().GetString("shell")
+		if err != nil {
+			return err
+		}
+		if shellName == "" {
+			shellName = defaultShell()
+		}
+		shell, err := toolbox.ParseShell(shellName)
 		if err != nil {
-			return fmt.Errorf("error marshalling tools to json: %w", err)
+			return err
 		}
-		fmt.Println(string(j))
+		options, err := makeOptions()
+		if err != nil {
+			return err
+		}
+		script, err := toolbox.Activate(shell, options...)
+		if err != nil {
+			return err
+		}
+		fmt.Print(script)
 		return nil
 	},
 }
 
+// defaultShell guesses the kind of shell being used from $SHELL
+func defaultShell() string {
+	shell := filepath.Base(os.Getenv("SHELL"))
+	if _, err := toolbox.ParseShell(shell); err == nil {
+		return shell
+	}
+	return toolbox.PosixShell.String()
+}
+
+var shellCommand = &cobra.Command{
+	Use:   "shell",
+	Short: "Start a shell with the vendored tools activated",
+	Long:  "Starts $SHELL in the same environment that \"toolbox do\" runs commands in, so vendored tools can be run directly.  Exit the shell to return to your normal environment.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		options, err := makeOptions()
+		if err != nil {
+			return err
+		}
+		return toolbox.Subshell(options...)
+	},
+}
+
+var runCommand = &cobra.Command{
+	Use:   "run <task...>",
+	Short: "Run tasks from the configuration file",
+	Long:  "Runs the named tasks from the \"tasks\" section of the configuration file, along with the tasks they depend on, with the vendored tools available the same way \"toolbox do\" makes them available.  Commands are run from the base directory.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		list, err := cmd.Flags().GetBool("list")
+		if err != nil {
+			return err
+		}
+		options, err := makeOptions()
+		if err != nil {
+			return err
+		}
+		tasks, err := configTasks()
+		if err != nil {
+			return err
+		}
+		options = append(options, toolbox.TasksOption(tasks))
+
+		if list {
+			tasks, err := toolbox.ListTasks(options...)
+			if err != nil {
+				return err
+			}
+			rows := make([][]string, len(tasks))
+			for i, t := range tasks {
+				rows[i] = []string{t.Name, strings.Join(t.Deps, ", "), t.Description}
+			}
+			return printTable(os.Stdout, []string{"TASK", "DEPENDS ON", "DESCRIPTION"}, rows)
+		}
+
+		if len(args) == 0 {
+			return errors.New("no task given, use --list to see the available tasks")
+		}
+		noSync, err := cmd.Flags().GetBool("no-sync")
+		if err != nil {
+			return err
+		}
+		options = append(options, toolbox.AutoSyncOption(!noSync))
+		return toolbox.RunTasks(args, options...)
+	},
+}
+
+var generateCommand = &cobra.Command{
+	Use:   "generate [packages]",
+	Short: "Run go generate using the vendored tools",
+	Long:  "Runs \"go generate\" on the given packages (or the package in the current directory) with the vendored tools on the PATH, after installing any tracked tool that the //go:generate directives use, if it's missing or out of date.  Prints a warning for every directive that runs a tool toolbox doesn't track.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		check, err := cmd.Flags().GetBool("check")
+		if err != nil {
+			return err
+		}
+		noSync, err := cmd.Flags().GetBool("no-sync")
+		if err != nil {
+			return err
+		}
+		options, err := makeOptions()
+		if err != nil {
+			return err
+		}
+
+		warnUntracked := func(gens []*toolbox.Generator) int {
+			untracked := 0
+			for _, g := range gens {
+				if g.Tracked {
+					continue
+				}
+				untracked++
+				fmt.Fprintf(os.Stderr, "warning: %s:%d: %s is not tracked by toolbox, run \"toolbox add\" to track it\n", g.File, g.Line, g.Command)
+			}
+			return untracked
+		}
+
+		if check {
+			gens, err := toolbox.Generators(args, options...)
+			if err != nil {
+				return err
+			}
+			if untracked := warnUntracked(gens); untracked > 0 {
+				return fmt.Errorf("%d go:generate directive(s) use untracked tools", untracked)
+			}
+			return nil
+		}
+
+		options = append(options, toolbox.AutoSyncOption(!noSync))
+		gens, err := toolbox.Generate(args, options...)
+		warnUntracked(gens)
+		return err
+	},
+}
+
+var migrateCommand = &cobra.Command{
+	Use:   "migrate <toolsfile|gomod>",
+	Short: "Move the list of tools to a different backend",
+	Long:  "Converts the tools in the tools file into tool directives in go.mod (\"gomod\"), or converts tool directives back into the tools file (\"toolsfile\").  Remember to set the backend option to match afterwards.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		backend, err := toolbox.ParseBackend(args[0])
+		if err != nil {
+			return err
+		}
+		options, err := makeOptions()
+		if err != nil {
+			return err
+		}
+		return toolbox.Migrate(backend, options...)
+	},
+}
+
 func init() {
+	doCommand.Flags().Bool("no-sync", false, "Don't install the tool first if it's missing or out of date.")
+	doCommand.Flags().Bool(strictFlag, false, "Fail if the command is a tracked tool that isn't installed, instead of running whatever version is on the PATH.")
+	viper.BindPFlag(strictFlag, doCommand.Flags().Lookup(strictFlag))
+	doCommand.Flags().Bool(isolatedFlag, false, "Run the command with a PATH containing only the vendored tools and the allowed commands, so that nothing else installed on this machine can be used by accident.  Implies --strict.")
+	viper.BindPFlag(isolatedFlag, doCommand.Flags().Lookup(isolatedFlag))
+	doCommand.Flags().StringSlice(allowedCommandsFlag, nil, "Commands from your PATH, such as \"go,git,sh\", that are still available with --isolated.")
+	viper.BindPFlag(allowedCommandsFlag, doCommand.Flags().Lookup(allowedCommandsFlag))
 	rootCmd.AddCommand(doCommand)
 	rootCmd.AddCommand(addCommand)
+	rootCmd.AddCommand(addArtifactCommand)
 	rootCmd.AddCommand(removeCommand)
+	syncCommand.Flags().IntP(jobsFlag, "j", 1, "The maximum number of tools to install in parallel.")
+	viper.BindPFlag(jobsFlag, syncCommand.Flags().Lookup(jobsFlag))
+	syncCommand.Flags().Bool(forceFlag, false, "Reinstall all tools, even those that appear to be up to date.")
+	viper.BindPFlag(forceFlag, syncCommand.Flags().Lookup(forceFlag))
 	rootCmd.AddCommand(syncCommand)
+	addCommand.Flags().String("replace", "", "Build the dependency from a fork or local checkout, by writing a replace directive for its module.  Takes a local directory, or \"module@version\".")
+	addCommand.Flags().Bool("no-replace", false, "Remove any replacement of the dependency's module, so it's built from the module itself again.")
+	addCommand.Flags().StringToString("env", nil, "Environment variables the dependency needs, such as \"FOO=bar\", which are set whenever tools are run.")
+	addCommand.Flags().String("name", "", "Install the dependency's binary under this name, instead of the name go install would give it.")
+
+	addArtifactCommand.Flags().String("archive", "", "The format of the download, one of \"tar.gz\", \"zip\", or \"raw\".  Defaults to guessing from the url's extension.")
+	addArtifactCommand.Flags().String("path", "", "The path of the binary inside of the archive.  May use the same templates as the url.")
+	addArtifactCommand.Flags().StringToString("os-name", nil, "Renames a GOOS to match the release's naming, such as \"darwin=osx\".")
+	addArtifactCommand.Flags().StringToString("arch-name", nil, "Renames a GOARCH to match the release's naming, such as \"amd64=x86_64\".")
+	addArtifactCommand.Flags().String("sha256", "", "The expected sha256 of the download on this platform.  Either this or --checksums is required, unless --trust-download is given.")
+	addArtifactCommand.Flags().Bool("trust-download", false, "Accept the download without an expected hash, and record its hash, so that only later downloads are checked.")
+	addArtifactCommand.Flags().String("checksums", "", "The url of the release's checksums file, which every download is checked against.  May use the same templates as the url.")
+	addArtifactCommand.Flags().String("signature", "", "The url of a signature of the checksums file, either a raw ed25519 signature in base64, or a legacy minisign signature made with \"minisign -S -l\".  Prehashed minisign signatures aren't supported.  May use the same templates as the url.")
+	addArtifactCommand.Flags().String("public-key", "", "The key that the checksums file is signed with, either a base64 encoded ed25519 public key, or a minisign public key.")
+
+	listCommand.Flags().Bool("modules", false, "Group tools under the module that provides them.")
+	listCommand.Flags().String(formatFlag, "", "The output format, one of \"table\", \"json\", \"yaml\", \"csv\", or a go text/template such as \"{{.Package}} {{.Version}}\", which is executed for each tool (or module, with --modules).  Defaults to \"table\" when printing to a terminal, and \"json\" otherwise.")
 	rootCmd.AddCommand(listCommand)
+	outdatedCommand.Flags().String(formatFlag, tableFormat, "The output format, either \"table\" or \"json\".")
+	rootCmd.AddCommand(outdatedCommand)
+	upgradeCommand.Flags().Bool("all", false, "Upgrade all dependencies.")
+	upgradeCommand.Flags().Bool("patch", false, "Only upgrade to newer patch versions.")
+	upgradeCommand.Flags().Bool("minor", false, "Upgrade to newer minor or patch versions. This is the default.")
+	rootCmd.AddCommand(upgradeCommand)
+	verifyCommand.Flags().String(formatFlag, tableFormat, "The output format, either \"table\" or \"json\".")
+	rootCmd.AddCommand(verifyCommand)
+	rootCmd.AddCommand(migrateCommand)
+	doctorCommand.Flags().String(formatFlag, tableFormat, "The output format, either \"table\" or \"json\".")
+	rootCmd.AddCommand(doctorCommand)
+	shimsCommand.Flags().String("dir", "bin", "The directory to write scripts to.  Relative paths are relative to the base directory.")
+	shimsCommand.Flags().String("toolbox", "", "The toolbox executable that scripts run.  Defaults to \"toolbox\", found on the PATH.")
+	rootCmd.AddCommand(shimsCommand)
+	envCommand.Flags().String("shell", "", "The shell to print commands for, one of \"bash\", \"zsh\", \"fish\", or \"posix\".  Defaults to guessing from $SHELL.")
+	rootCmd.AddCommand(envCommand)
+	rootCmd.AddCommand(shellCommand)
+	runCommand.Flags().Bool("list", false, "List the available tasks instead of running one.")
+	runCommand.Flags().Bool("no-sync", false, "Don't install tools that are missing or out of date before running them.")
+	rootCmd.AddCommand(runCommand)
+	generateCommand.Flags().Bool("check", false, "Only check that every tool used by go:generate directives is tracked, and exit with an error if not, without generating anything.")
+	generateCommand.Flags().Bool("no-sync", false, "Don't install tools that are missing or out of date before generating.")
+	rootCmd.AddCommand(generateCommand)
 }